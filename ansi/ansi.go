@@ -0,0 +1,236 @@
+// Package ansi implements a parser for ECMA-48 terminal output, following
+// the DEC/VT500 state diagram by Paul Williams
+// (https://vt100.net/emu/dec_ansi_parser).
+//
+// The parser is table driven and keeps its state between writes, so escape
+// sequences, strings and UTF-8 characters may be split at any byte. It
+// reports what it finds to a Handler as typed events. Input is UTF-8; C1
+// controls are recognized as the code points U+0080 to U+009F.
+package ansi
+
+import (
+	"unicode/utf8"
+)
+
+// Limits on what the parser collects for a single sequence. Anything
+// beyond them is dropped.
+const (
+	MaxParams        = 32
+	MaxIntermediates = 2
+	MaxStringLen     = 1 << 16
+	maxParamValue    = 65535
+)
+
+// CSI is a control sequence: CSI, an optional private marker, parameters,
+// intermediates and a final byte.
+type CSI struct {
+	Private       byte    // '<', '=', '>' or '?' starting the parameters, or 0
+	Params        [][]int // parameters, each with its ':' separated sub-parameters
+	Intermediates []byte
+	Final         byte
+}
+
+// Param returns the first value of parameter i, or def when it is missing
+// or zero.
+func (c *CSI) Param(i, def int) int {
+	if i < len(c.Params) && len(c.Params[i]) > 0 && c.Params[i][0] != 0 {
+		return c.Params[i][0]
+	}
+	return def
+}
+
+// ESC is an escape sequence other than the introducers of CSI, OSC, DCS
+// and the other control strings.
+type ESC struct {
+	Intermediates []byte
+	Final         byte
+}
+
+// OSC is an operating system command string.
+type OSC struct {
+	Data []byte
+}
+
+// DCS is a device control string: a header shaped like a control sequence
+// followed by the data up to the string terminator.
+type DCS struct {
+	Private       byte
+	Params        [][]int
+	Intermediates []byte
+	Final         byte
+	Data          []byte
+}
+
+// Handler receives the events found by a Parser. The slices in events are
+// only valid during the call.
+type Handler interface {
+	// Print is called for a graphic character.
+	Print(r rune)
+	// Execute is called for a C0 or C1 control function.
+	Execute(r rune)
+	ESC(e *ESC)
+	CSI(c *CSI)
+	OSC(o *OSC)
+	DCS(d *DCS)
+}
+
+// Parser splits terminal output into events for a Handler.
+type Parser struct {
+	h     Handler
+	state state
+
+	utf8 [utf8.UTFMax]byte
+	nutf int
+
+	private byte
+	inter   []byte
+	params  [][]int
+	str     []byte
+	dcs     DCS
+}
+
+// NewParser returns a Parser reporting to h.
+func NewParser(h Handler) *Parser {
+	return &Parser{h: h}
+}
+
+// Reset returns p to the ground state, dropping any partial sequence.
+func (p *Parser) Reset() {
+	p.state = stateGround
+	p.nutf = 0
+	p.clear()
+	p.str = p.str[:0]
+}
+
+// Write parses b. It never fails.
+func (p *Parser) Write(b []byte) (int, error) {
+	for _, c := range b {
+		if p.nutf > 0 {
+			if !utf8.RuneStart(c) {
+				p.utf8[p.nutf] = c
+				p.nutf++
+				if utf8.FullRune(p.utf8[:p.nutf]) {
+					r, _ := utf8.DecodeRune(p.utf8[:p.nutf])
+					p.nutf = 0
+					p.advance(r)
+				}
+				continue
+			}
+			// the character was cut short by the start of another
+			p.nutf = 0
+			p.advance(utf8.RuneError)
+		}
+		switch {
+		case c < utf8.RuneSelf:
+			p.advance(rune(c))
+		case !utf8.RuneStart(c) || utf8.FullRune([]byte{c}):
+			p.advance(utf8.RuneError)
+		default:
+			p.utf8[0] = c
+			p.nutf = 1
+		}
+	}
+	return len(b), nil
+}
+
+func (p *Parser) advance(r rune) {
+	if r == 0x18 || r == 0x1a {
+		// CAN and SUB cancel any sequence or string without dispatching it.
+		p.state = stateGround
+		p.str = p.str[:0]
+		p.h.Execute(r)
+		return
+	}
+
+	class := r
+	if class > classHigh {
+		class = classHigh
+	}
+	t := table[p.state][class]
+	if t.next == stateNone {
+		p.do(t.action, r)
+		return
+	}
+	p.exit()
+	p.do(t.action, r)
+	p.state = t.next
+	p.enter()
+}
+
+func (p *Parser) exit() {
+	switch p.state {
+	case stateOSCString:
+		p.h.OSC(&OSC{Data: p.str})
+		p.str = p.str[:0]
+	case stateDCSPassthrough:
+		p.dcs.Data = p.str
+		p.h.DCS(&p.dcs)
+		p.str = p.str[:0]
+	}
+}
+
+func (p *Parser) enter() {
+	switch p.state {
+	case stateEscape, stateCSIEntry, stateDCSEntry:
+		p.clear()
+	case stateOSCString, stateDCSPassthrough:
+		p.str = p.str[:0]
+	}
+}
+
+func (p *Parser) clear() {
+	p.private = 0
+	p.inter = p.inter[:0]
+	p.params = p.params[:0]
+}
+
+func (p *Parser) do(a action, r rune) {
+	switch a {
+	case actionPrint:
+		p.h.Print(r)
+	case actionExecute:
+		p.h.Execute(r)
+	case actionPrivate:
+		p.private = byte(r)
+	case actionCollect:
+		if len(p.inter) < MaxIntermediates {
+			p.inter = append(p.inter, byte(r))
+		}
+	case actionParam:
+		p.param(byte(r))
+	case actionESCDispatch:
+		p.h.ESC(&ESC{Intermediates: p.inter, Final: byte(r)})
+	case actionCSIDispatch:
+		p.h.CSI(&CSI{Private: p.private, Params: p.params, Intermediates: p.inter, Final: byte(r)})
+	case actionHook:
+		p.dcs = DCS{Private: p.private, Params: p.params, Intermediates: p.inter, Final: byte(r)}
+	case actionPut:
+		if len(p.str)+utf8.UTFMax <= MaxStringLen {
+			p.str = utf8.AppendRune(p.str, r)
+		}
+	}
+}
+
+func (p *Parser) param(c byte) {
+	if len(p.params) == 0 {
+		p.params = append(p.params, []int{0})
+	}
+	last := len(p.params) - 1
+	switch c {
+	case ';':
+		if len(p.params) < MaxParams {
+			p.params = append(p.params, []int{0})
+		}
+	case ':':
+		if len(p.params[last]) < MaxParams {
+			p.params[last] = append(p.params[last], 0)
+		}
+	default:
+		sub := p.params[last]
+		v := &sub[len(sub)-1]
+		*v = *v*10 + int(c-'0')
+		if *v > maxParamValue {
+			*v = maxParamValue
+		}
+	}
+}
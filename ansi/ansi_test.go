@@ -0,0 +1,272 @@
+package ansi
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// recorder describes the events it receives, one per line, with the
+// characters printed in a row joined.
+type recorder struct {
+	events []string
+	str    string // the last OSC or DCS string
+}
+
+func (r *recorder) add(format string, a ...interface{}) {
+	r.events = append(r.events, fmt.Sprintf(format, a...))
+}
+
+func (r *recorder) Print(c rune) {
+	if n := len(r.events); n > 0 && strings.HasPrefix(r.events[n-1], "print ") {
+		r.events[n-1] += string(c)
+		return
+	}
+	r.add("print %c", c)
+}
+
+func (r *recorder) Execute(c rune) { r.add("execute %#x", c) }
+func (r *recorder) ESC(e *ESC)     { r.add("esc %q %c", e.Intermediates, e.Final) }
+
+func (r *recorder) CSI(c *CSI) {
+	r.add("csi %q %v %q %c", string(c.Private), c.Params, c.Intermediates, c.Final)
+}
+
+func (r *recorder) OSC(o *OSC) {
+	r.str = string(o.Data)
+	r.add("osc %q", o.Data)
+}
+
+func (r *recorder) DCS(d *DCS) {
+	r.str = string(d.Data)
+	r.add("dcs %q %v %q %c %q", string(d.Private), d.Params, d.Intermediates, d.Final, d.Data)
+}
+
+func parse(chunks ...string) []string {
+	rec := &recorder{}
+	p := NewParser(rec)
+	for _, s := range chunks {
+		p.Write([]byte(s))
+	}
+	return rec.events
+}
+
+func TestParser(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want []string
+	}{
+		{
+			name: "text and controls",
+			in:   "ab\r\n日本",
+			want: []string{"print ab", "execute 0xd", "execute 0xa", "print 日本"},
+		},
+		{
+			name: "csi",
+			in:   "\x1b[1;31mx\x1b[m",
+			want: []string{`csi "\x00" [[1] [31]] "" m`, "print x", `csi "\x00" [] "" m`},
+		},
+		{
+			name: "csi private marker and intermediate",
+			in:   "\x1b[?1049h\x1b[>5l\x1b[2 q",
+			want: []string{`csi "?" [[1049]] "" h`, `csi ">" [[5]] "" l`, `csi "\x00" [[2]] " " q`},
+		},
+		{
+			name: "csi sub-parameters",
+			in:   "\x1b[38:2::1:2:3m",
+			want: []string{`csi "\x00" [[38 2 0 1 2 3]] "" m`},
+		},
+		{
+			name: "csi with a misplaced private marker is ignored",
+			in:   "\x1b[1?2hx",
+			want: []string{"print x"},
+		},
+		{
+			name: "control inside csi",
+			in:   "\x1b[1\n2H",
+			want: []string{"execute 0xa", `csi "\x00" [[12]] "" H`},
+		},
+		{
+			name: "esc",
+			in:   "\x1b7\x1b(0\x1b#8",
+			want: []string{`esc "" 7`, `esc "(" 0`, `esc "#" 8`},
+		},
+		{
+			name: "osc ended by bel",
+			in:   "\x1b]2;title\ax",
+			want: []string{`osc "2;title"`, "print x"},
+		},
+		{
+			name: "osc ended by st",
+			in:   "\x1b]8;;http://a/\x1b\\x",
+			want: []string{`osc "8;;http://a/"`, `esc "" \`, "print x"},
+		},
+		{
+			name: "osc keeps utf-8",
+			in:   "\x1b]0;日本\a",
+			want: []string{`osc "0;日本"`},
+		},
+		{
+			name: "dcs",
+			in:   "\x1bP1;2|a\nb\x1b\\x",
+			want: []string{`dcs "\x00" [[1] [2]] "" | "a\nb"`, `esc "" \`, "print x"},
+		},
+		{
+			name: "dcs private marker and intermediate",
+			in:   "\x1bP>$qm\x1b\\",
+			want: []string{`dcs ">" [] "$" q "m"`, `esc "" \`},
+		},
+		{
+			name: "dcs with a misplaced private marker is ignored",
+			in:   "\x1bP1?2qdata\x1b\\x",
+			want: []string{`esc "" \`, "print x"},
+		},
+		{
+			name: "sos pm and apc strings are ignored",
+			in:   "\x1bXsos\x1b\\\x1b^pm\a\x1b\\\x1b_apc\x1b\\x",
+			want: []string{`esc "" \`, `esc "" \`, `esc "" \`, "print x"},
+		},
+		{
+			name: "c1 controls",
+			in:   "\u0085\u0084\u008d\u0088",
+			want: []string{"execute 0x85", "execute 0x84", "execute 0x8d", "execute 0x88"},
+		},
+		{
+			name: "c1 csi",
+			in:   "\u009b2J",
+			want: []string{`csi "\x00" [[2]] "" J`},
+		},
+		{
+			name: "c1 osc ended by c1 st",
+			in:   "\u009d2;t\u009cx",
+			want: []string{`osc "2;t"`, "print x"},
+		},
+		{
+			name: "c1 dcs ended by c1 st",
+			in:   "\u0090qdata\u009c",
+			want: []string{`dcs "\x00" [] "" q "data"`},
+		},
+		{
+			name: "c1 apc ended by c1 st",
+			in:   "\u009fapc\u009cx",
+			want: []string{"print x"},
+		},
+		{
+			name: "c1 control ends a sequence",
+			in:   "\x1b[12\u0085x",
+			want: []string{"execute 0x85", "print x"},
+		},
+		{
+			name: "raw 8-bit bytes are not c1 controls",
+			in:   "\x9b2J",
+			want: []string{"print �2J"},
+		},
+		{
+			name: "can aborts csi",
+			in:   "\x1b[12\x18x",
+			want: []string{"execute 0x18", "print x"},
+		},
+		{
+			name: "sub aborts esc",
+			in:   "\x1b(\x1ax",
+			want: []string{"execute 0x1a", "print x"},
+		},
+		{
+			name: "can aborts osc",
+			in:   "\x1b]2;title\x18x\a",
+			want: []string{"execute 0x18", "print x", "execute 0x7"},
+		},
+		{
+			name: "sub aborts dcs",
+			in:   "\x1bPqdata\x1ax",
+			want: []string{"execute 0x1a", "print x"},
+		},
+		{
+			name: "esc restarts a sequence",
+			in:   "\x1b[12\x1b[3H",
+			want: []string{`csi "\x00" [[3]] "" H`},
+		},
+		{
+			name: "utf-8 cut short",
+			in:   "\xe6\x97x\xff",
+			want: []string{"print �x�"},
+		},
+	}
+	for _, tt := range tests {
+		got := parse(tt.in)
+		if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+			t.Errorf("%s: got\n%s\nwant\n%s", tt.name, strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+		}
+	}
+}
+
+func TestSplitWrites(t *testing.T) {
+	in := "a\x1b[1;38:5:200m日\x1b]2;タイトル\x1b\\\u009b?25l\x1bP1$r0m\x1b\\\x1b_x\x1b\\\x1b(0q\u0085"
+	want := parse(in)
+	for i := 1; i < len(in); i++ {
+		if got := parse(in[:i], in[i:]); strings.Join(got, "\n") != strings.Join(want, "\n") {
+			t.Errorf("split at %d: got\n%s\nwant\n%s", i, strings.Join(got, "\n"), strings.Join(want, "\n"))
+		}
+	}
+
+	// a byte at a time
+	chunks := make([]string, len(in))
+	for i := 0; i < len(in); i++ {
+		chunks[i] = in[i : i+1]
+	}
+	if got := parse(chunks...); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("byte at a time: got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestLimits(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "parameters",
+			in:   "\x1b[" + strings.Repeat(";", MaxParams+10) + "m",
+			want: `csi "\x00" [` + strings.Repeat("[0] ", MaxParams-1) + `[0]] "" m`,
+		},
+		{
+			name: "sub-parameters",
+			in:   "\x1b[1" + strings.Repeat(":", MaxParams+10) + "m",
+			want: `csi "\x00" [[1` + strings.Repeat(" 0", MaxParams-1) + `]] "" m`,
+		},
+		{
+			name: "values",
+			in:   "\x1b[99999999999999999999H",
+			want: `csi "\x00" [[65535]] "" H`,
+		},
+		{
+			name: "intermediates",
+			in:   "\x1b($%#0",
+			want: `esc "($" 0`,
+		},
+	}
+	for _, tt := range tests {
+		got := parse(tt.in)
+		if len(got) == 0 || got[0] != tt.want {
+			t.Errorf("%s: got %.200q, want %.200q", tt.name, got, tt.want)
+		}
+	}
+
+	// strings are cut at MaxStringLen without splitting a character
+	for _, in := range []string{
+		"\x1b]2;" + strings.Repeat("x", MaxStringLen+100) + "\a",
+		"\x1bPq" + strings.Repeat("日", MaxStringLen) + "\x1b\\",
+	} {
+		rec := &recorder{}
+		NewParser(rec).Write([]byte(in))
+		if n := len(rec.str); n > MaxStringLen || n < MaxStringLen-utf8.UTFMax {
+			t.Errorf("%.8q: string of %d bytes, want up to %d", in, n, MaxStringLen)
+		}
+		if !utf8.ValidString(rec.str) {
+			t.Errorf("%.8q: string cut inside a character", in)
+		}
+	}
+}
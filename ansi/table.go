@@ -0,0 +1,140 @@
+package ansi
+
+type state uint8
+
+const (
+	stateGround state = iota
+	stateEscape
+	stateEscapeIntermediate
+	stateCSIEntry
+	stateCSIParam
+	stateCSIIntermediate
+	stateCSIIgnore
+	stateDCSEntry
+	stateDCSParam
+	stateDCSIntermediate
+	stateDCSPassthrough
+	stateDCSIgnore
+	stateOSCString
+	stateSOSPMAPCString
+	numStates
+
+	stateNone state = 0xff
+)
+
+type action uint8
+
+const (
+	actionNone action = iota
+	actionPrint
+	actionExecute
+	actionPrivate
+	actionCollect
+	actionParam
+	actionESCDispatch
+	actionCSIDispatch
+	actionHook
+	actionPut
+)
+
+type transition struct {
+	action action
+	next   state
+}
+
+// classHigh is the input class of all characters from U+00A0 up, which
+// behave like printable ASCII.
+const classHigh = 0xa0
+
+var table [numStates][classHigh + 1]transition
+
+func set(s state, lo, hi rune, a action, next state) {
+	for c := lo; c <= hi; c++ {
+		table[s][c] = transition{action: a, next: next}
+	}
+}
+
+// c0 sets the handling of the C0 controls other than CAN, SUB and ESC.
+func c0(s state, a action) {
+	set(s, 0x00, 0x17, a, stateNone)
+	set(s, 0x19, 0x19, a, stateNone)
+	set(s, 0x1c, 0x1f, a, stateNone)
+}
+
+func init() {
+	for s := state(0); s < numStates; s++ {
+		set(s, 0x00, classHigh, actionNone, stateNone)
+
+		// transitions from anywhere
+		set(s, 0x1b, 0x1b, actionNone, stateEscape)
+		set(s, 0x80, 0x8f, actionExecute, stateGround)
+		set(s, 0x91, 0x97, actionExecute, stateGround)
+		set(s, 0x99, 0x9a, actionExecute, stateGround)
+		set(s, 0x9c, 0x9c, actionNone, stateGround)
+		set(s, 0x90, 0x90, actionNone, stateDCSEntry)
+		set(s, 0x98, 0x98, actionNone, stateSOSPMAPCString)
+		set(s, 0x9e, 0x9f, actionNone, stateSOSPMAPCString)
+		set(s, 0x9b, 0x9b, actionNone, stateCSIEntry)
+		set(s, 0x9d, 0x9d, actionNone, stateOSCString)
+	}
+
+	c0(stateGround, actionExecute)
+	set(stateGround, 0x20, 0x7e, actionPrint, stateNone)
+	set(stateGround, classHigh, classHigh, actionPrint, stateNone)
+
+	c0(stateEscape, actionExecute)
+	set(stateEscape, 0x20, 0x2f, actionCollect, stateEscapeIntermediate)
+	set(stateEscape, 0x30, 0x7e, actionESCDispatch, stateGround)
+	set(stateEscape, 0x5b, 0x5b, actionNone, stateCSIEntry)
+	set(stateEscape, 0x5d, 0x5d, actionNone, stateOSCString)
+	set(stateEscape, 0x50, 0x50, actionNone, stateDCSEntry)
+	set(stateEscape, 0x58, 0x58, actionNone, stateSOSPMAPCString)
+	set(stateEscape, 0x5e, 0x5f, actionNone, stateSOSPMAPCString)
+
+	c0(stateEscapeIntermediate, actionExecute)
+	set(stateEscapeIntermediate, 0x20, 0x2f, actionCollect, stateNone)
+	set(stateEscapeIntermediate, 0x30, 0x7e, actionESCDispatch, stateGround)
+
+	c0(stateCSIEntry, actionExecute)
+	set(stateCSIEntry, 0x20, 0x2f, actionCollect, stateCSIIntermediate)
+	set(stateCSIEntry, 0x30, 0x3b, actionParam, stateCSIParam)
+	set(stateCSIEntry, 0x3c, 0x3f, actionPrivate, stateCSIParam)
+	set(stateCSIEntry, 0x40, 0x7e, actionCSIDispatch, stateGround)
+
+	c0(stateCSIParam, actionExecute)
+	set(stateCSIParam, 0x30, 0x3b, actionParam, stateNone)
+	set(stateCSIParam, 0x3c, 0x3f, actionNone, stateCSIIgnore)
+	set(stateCSIParam, 0x20, 0x2f, actionCollect, stateCSIIntermediate)
+	set(stateCSIParam, 0x40, 0x7e, actionCSIDispatch, stateGround)
+
+	c0(stateCSIIntermediate, actionExecute)
+	set(stateCSIIntermediate, 0x20, 0x2f, actionCollect, stateNone)
+	set(stateCSIIntermediate, 0x30, 0x3f, actionNone, stateCSIIgnore)
+	set(stateCSIIntermediate, 0x40, 0x7e, actionCSIDispatch, stateGround)
+
+	c0(stateCSIIgnore, actionExecute)
+	set(stateCSIIgnore, 0x40, 0x7e, actionNone, stateGround)
+
+	set(stateDCSEntry, 0x20, 0x2f, actionCollect, stateDCSIntermediate)
+	set(stateDCSEntry, 0x30, 0x3b, actionParam, stateDCSParam)
+	set(stateDCSEntry, 0x3c, 0x3f, actionPrivate, stateDCSParam)
+	set(stateDCSEntry, 0x40, 0x7e, actionHook, stateDCSPassthrough)
+
+	set(stateDCSParam, 0x30, 0x3b, actionParam, stateNone)
+	set(stateDCSParam, 0x3c, 0x3f, actionNone, stateDCSIgnore)
+	set(stateDCSParam, 0x20, 0x2f, actionCollect, stateDCSIntermediate)
+	set(stateDCSParam, 0x40, 0x7e, actionHook, stateDCSPassthrough)
+
+	set(stateDCSIntermediate, 0x20, 0x2f, actionCollect, stateNone)
+	set(stateDCSIntermediate, 0x30, 0x3f, actionNone, stateDCSIgnore)
+	set(stateDCSIntermediate, 0x40, 0x7e, actionHook, stateDCSPassthrough)
+
+	c0(stateDCSPassthrough, actionPut)
+	set(stateDCSPassthrough, 0x20, 0x7e, actionPut, stateNone)
+	set(stateDCSPassthrough, classHigh, classHigh, actionPut, stateNone)
+
+	// xterm also ends OSC strings with BEL
+	set(stateOSCString, 0x07, 0x07, actionNone, stateGround)
+	set(stateOSCString, 0x20, 0x7e, actionPut, stateNone)
+	set(stateOSCString, classHigh, classHigh, actionPut, stateNone)
+}
@@ -0,0 +1,135 @@
+// Package asciicast implements reading and writing of asciicast v2
+// recordings as used by asciinema.
+//
+// A recording is a JSON header line followed by one JSON array per event:
+// the time in seconds since the start, the event type and its data.
+package asciicast
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Version is the asciicast format version handled by this package.
+const Version = 2
+
+// Event types.
+const (
+	Output = "o"
+	Input  = "i"
+	Marker = "m"
+	Resize = "r"
+)
+
+// Header is the first line of a recording.
+type Header struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp,omitempty"`
+	Title     string            `json:"title,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+// Event is a single line following the header.
+type Event struct {
+	Time time.Duration // time since the start of the recording
+	Type string
+	Data string
+}
+
+// Reader reads events from an asciicast stream.
+type Reader struct {
+	Header Header
+	dec    *json.Decoder
+}
+
+// NewReader reads the header from r and returns a Reader for the events
+// following it.
+func NewReader(r io.Reader) (*Reader, error) {
+	dec := json.NewDecoder(r)
+	var h Header
+	if err := dec.Decode(&h); err != nil {
+		return nil, err
+	}
+	if h.Version != Version {
+		return nil, fmt.Errorf("asciicast: unsupported version %d", h.Version)
+	}
+	return &Reader{Header: h, dec: dec}, nil
+}
+
+// ReadEvent reads the next event. It returns io.EOF at the end of the
+// stream.
+func (r *Reader) ReadEvent() (*Event, error) {
+	var ev []json.RawMessage
+	if err := r.dec.Decode(&ev); err != nil {
+		return nil, err
+	}
+	if len(ev) != 3 {
+		return nil, errors.New("asciicast: malformed event")
+	}
+	var sec float64
+	var e Event
+	if err := json.Unmarshal(ev[0], &sec); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(ev[1], &e.Type); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(ev[2], &e.Data); err != nil {
+		return nil, err
+	}
+	e.Time = time.Duration(sec * float64(time.Second))
+	return &e, nil
+}
+
+// Writer writes events to an asciicast stream.
+type Writer struct {
+	w *bufio.Writer
+}
+
+// NewWriter writes h to w and returns a Writer for the events following it.
+func NewWriter(w io.Writer, h *Header) (*Writer, error) {
+	hh := *h
+	hh.Version = Version
+	b, err := json.Marshal(&hh)
+	if err != nil {
+		return nil, err
+	}
+	bw := bufio.NewWriter(w)
+	bw.Write(b)
+	if err = bw.WriteByte('\n'); err != nil {
+		return nil, err
+	}
+	return &Writer{w: bw}, nil
+}
+
+// WriteEvent writes e as a single line.
+func (w *Writer) WriteEvent(e *Event) error {
+	b, err := json.Marshal(e.Type)
+	if err != nil {
+		return err
+	}
+	d, err := json.Marshal(e.Data)
+	if err != nil {
+		return err
+	}
+	w.w.WriteByte('[')
+	w.w.WriteString(strconv.FormatFloat(e.Time.Seconds(), 'f', 6, 64))
+	w.w.WriteString(", ")
+	w.w.Write(b)
+	w.w.WriteString(", ")
+	w.w.Write(d)
+	_, err = w.w.WriteString("]\n")
+	return err
+}
+
+// Flush writes any buffered events to the underlying writer.
+func (w *Writer) Flush() error {
+	return w.w.Flush()
+}
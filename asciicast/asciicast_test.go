@@ -0,0 +1,114 @@
+package asciicast
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRoundTrip(t *testing.T) {
+	h := &Header{
+		Width:     120,
+		Height:    40,
+		Timestamp: 1500000000,
+		Title:     "build log",
+		Env:       map[string]string{"TERM": "xterm-256color"},
+	}
+	events := []*Event{
+		{Time: 0, Type: Output, Data: "$ "},
+		{Time: 1500 * time.Millisecond, Type: Input, Data: "ls\r"},
+		{Time: 1501 * time.Millisecond, Type: Output, Data: "a\tb \"c\"\r\n\x1b[0m日本"},
+		{Time: time.Minute, Type: Resize, Data: "100x30"},
+		{Time: time.Minute + time.Microsecond, Type: Marker, Data: ""},
+	}
+
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range events {
+		if err := w.WriteEvent(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(buf.String(), "\n"); lines != 1+len(events) {
+		t.Errorf("%d lines, want %d", lines, 1+len(events))
+	}
+
+	r, err := NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := r.Header
+	if got.Version != Version || got.Width != h.Width || got.Height != h.Height ||
+		got.Timestamp != h.Timestamp || got.Title != h.Title || got.Env["TERM"] != h.Env["TERM"] {
+		t.Errorf("header %+v, want %+v", got, h)
+	}
+	for i, want := range events {
+		e, err := r.ReadEvent()
+		if err != nil {
+			t.Fatalf("event %d: %v", i, err)
+		}
+		if *e != *want {
+			t.Errorf("event %d: %+v, want %+v", i, e, want)
+		}
+	}
+	if _, err := r.ReadEvent(); err != io.EOF {
+		t.Errorf("got %v after the last event, want io.EOF", err)
+	}
+}
+
+func TestWriteEvent(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, &Header{Width: 80, Height: 24})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.WriteEvent(&Event{Time: 1234567 * time.Microsecond, Type: Output, Data: "hi\r\n"})
+	w.Flush()
+	want := `{"version":2,"width":80,"height":24}` + "\n" + `[1.234567, "o", "hi\r\n"]` + "\n"
+	if buf.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestReader(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		ok   bool
+	}{
+		{"asciinema", `{"version": 2, "width": 80, "height": 24}` + "\n" + `[0.5, "o", "x"]` + "\n", true},
+		{"version 1", `{"version": 1, "width": 80, "height": 24}` + "\n", false},
+		{"short event", `{"version": 2}` + "\n" + `[0.5, "o"]` + "\n", false},
+		{"bad time", `{"version": 2}` + "\n" + `["0.5", "o", "x"]` + "\n", false},
+		{"not json", "\x00\x00\x00\x00", false},
+	}
+	for _, tt := range tests {
+		r, err := NewReader(strings.NewReader(tt.in))
+		if err == nil {
+			_, err = r.ReadEvent()
+		}
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: got error %v", tt.name, err)
+		}
+	}
+
+	r, err := NewReader(strings.NewReader(`{"version": 2}` + "\n" + `[0.5, "o", "x"]` + "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := r.ReadEvent()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Time != 500*time.Millisecond || e.Type != Output || e.Data != "x" {
+		t.Errorf("got %+v", e)
+	}
+}
@@ -0,0 +1,131 @@
+package format
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Problems found by Check.
+var (
+	ErrTruncatedHeader = errors.New("truncated header")
+	ErrTruncatedData   = errors.New("truncated payload")
+	ErrBadTimestamp    = errors.New("microseconds out of range")
+	ErrTimeBackwards   = errors.New("timestamp goes backwards")
+)
+
+// Problem describes a damaged frame.
+type Problem struct {
+	Frame  int   // index of the frame
+	Offset int64 // byte offset of the frame header
+	Err    error
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("frame %d at offset %d: %v", p.Frame, p.Offset, p.Err)
+}
+
+// Report is the result of Check.
+type Report struct {
+	Frames   int   // number of frames written to the repaired copy
+	Size     int64 // number of bytes read
+	Problems []Problem
+}
+
+// OK reports whether the recording had no problems.
+func (r *Report) OK() bool {
+	return len(r.Problems) == 0
+}
+
+// CheckOptions controls how Check repairs a recording.
+type CheckOptions struct {
+	// Truncate keeps the readable part of a frame with a truncated
+	// payload instead of dropping it.
+	Truncate bool
+}
+
+// Check walks the recording in r and reports damaged frames. If w is not
+// nil, a repaired copy is written to it: frames whose timestamp goes
+// backwards get the time of the previous frame, a truncated last frame is
+// dropped or truncated, and everything after a corrupt header is dropped
+// because the next frame cannot be located.
+func Check(r io.Reader, w io.Writer, opt *CheckOptions) (*Report, error) {
+	if opt == nil {
+		opt = &CheckOptions{}
+	}
+	var fw *Writer
+	if w != nil {
+		fw = NewWriter(w)
+	}
+	rep := &Report{}
+	var c clock
+	var last time.Time
+
+	problem := func(n int, off int64, err error) {
+		rep.Problems = append(rep.Problems, Problem{Frame: n, Offset: off, Err: err})
+	}
+	emit := func(f *Frame) error {
+		rep.Frames++
+		if fw == nil {
+			return nil
+		}
+		return fw.WriteFrame(f)
+	}
+
+	for n := 0; ; n++ {
+		off := rep.Size
+		h, err := readHeader(r)
+		if err == io.EOF {
+			break
+		}
+		if err == io.ErrUnexpectedEOF {
+			problem(n, off, ErrTruncatedHeader)
+			break
+		}
+		if err != nil {
+			return rep, err
+		}
+		rep.Size += HeaderSize
+
+		if h.len > MaxFrameSize {
+			problem(n, off, fmt.Errorf("%w (%d bytes)", ErrFrameTooLarge, h.len))
+			break
+		}
+		if h.usec >= 1000000 {
+			problem(n, off, fmt.Errorf("%w (%d)", ErrBadTimestamp, h.usec))
+			break
+		}
+
+		t := c.time(h)
+		f := &Frame{Time: t, Elapsed: t.Sub(c.start), Data: make([]byte, h.len)}
+		nr, err := io.ReadFull(r, f.Data)
+		rep.Size += int64(nr)
+		truncated := err == io.EOF || err == io.ErrUnexpectedEOF
+		if err != nil && !truncated {
+			return rep, err
+		}
+
+		if n > 0 && f.Time.Before(last) {
+			problem(n, off, fmt.Errorf("%w (%v)", ErrTimeBackwards, last.Sub(f.Time)))
+			f.Time = last
+			f.Elapsed = last.Sub(c.start)
+		}
+		last = f.Time
+
+		if truncated {
+			problem(n, off, fmt.Errorf("%w (%d of %d bytes)", ErrTruncatedData, nr, h.len))
+			if !opt.Truncate || nr == 0 {
+				break
+			}
+			f.Data = f.Data[:nr]
+		}
+		if err = emit(f); err != nil {
+			return rep, err
+		}
+		if truncated {
+			break
+		}
+	}
+	return rep, nil
+}
@@ -0,0 +1,117 @@
+package format
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+func concat(frames ...[]byte) []byte {
+	var b []byte
+	for _, f := range frames {
+		b = append(b, f...)
+	}
+	return b
+}
+
+func TestCheck(t *testing.T) {
+	tooLarge := rawFrame(3, 0, "")
+	binary.LittleEndian.PutUint32(tooLarge[8:], MaxFrameSize+1)
+
+	tests := []struct {
+		name     string
+		rec      []byte
+		truncate bool
+		problems []error
+		repaired []byte
+	}{
+		{
+			name:     "good",
+			rec:      concat(rawFrame(1, 0, "a"), rawFrame(2, 0, "b")),
+			repaired: concat(rawFrame(1, 0, "a"), rawFrame(2, 0, "b")),
+		},
+		{
+			name:     "empty",
+			rec:      nil,
+			repaired: nil,
+		},
+		{
+			name:     "backwards",
+			rec:      concat(rawFrame(5, 0, "a"), rawFrame(4, 0, "b"), rawFrame(6, 0, "c")),
+			problems: []error{ErrTimeBackwards},
+			repaired: concat(rawFrame(5, 0, "a"), rawFrame(5, 0, "b"), rawFrame(6, 0, "c")),
+		},
+		{
+			name:     "truncated header",
+			rec:      concat(rawFrame(1, 0, "a"), rawFrame(2, 0, "b")[:5]),
+			problems: []error{ErrTruncatedHeader},
+			repaired: rawFrame(1, 0, "a"),
+		},
+		{
+			name:     "truncated payload dropped",
+			rec:      concat(rawFrame(1, 0, "a"), rawFrame(2, 0, "hello")[:HeaderSize+3]),
+			problems: []error{ErrTruncatedData},
+			repaired: rawFrame(1, 0, "a"),
+		},
+		{
+			name:     "truncated payload kept",
+			rec:      concat(rawFrame(1, 0, "a"), rawFrame(2, 0, "hello")[:HeaderSize+3]),
+			truncate: true,
+			problems: []error{ErrTruncatedData},
+			repaired: concat(rawFrame(1, 0, "a"), rawFrame(2, 0, "hel")),
+		},
+		{
+			name:     "bad microseconds",
+			rec:      concat(rawFrame(1, 0, "a"), rawFrame(2, 1000000, "b"), rawFrame(3, 0, "c")),
+			problems: []error{ErrBadTimestamp},
+			repaired: rawFrame(1, 0, "a"),
+		},
+		{
+			name:     "frame too large",
+			rec:      concat(rawFrame(1, 0, "a"), tooLarge, rawFrame(4, 0, "c")),
+			problems: []error{ErrFrameTooLarge},
+			repaired: rawFrame(1, 0, "a"),
+		},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		rep, err := Check(bytes.NewReader(tt.rec), &out, &CheckOptions{Truncate: tt.truncate})
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if len(rep.Problems) != len(tt.problems) {
+			t.Errorf("%s: problems %v, want %v", tt.name, rep.Problems, tt.problems)
+		} else {
+			for i, p := range rep.Problems {
+				if !errors.Is(p.Err, tt.problems[i]) {
+					t.Errorf("%s: problem %d is %v, want %v", tt.name, i, p.Err, tt.problems[i])
+				}
+			}
+		}
+		if rep.OK() != (len(tt.problems) == 0) {
+			t.Errorf("%s: OK() = %v", tt.name, rep.OK())
+		}
+		if !bytes.Equal(out.Bytes(), tt.repaired) {
+			t.Errorf("%s: repaired\n% x\nwant\n% x", tt.name, out.Bytes(), tt.repaired)
+		}
+	}
+}
+
+func TestCheckProblemLocation(t *testing.T) {
+	rec := concat(rawFrame(5, 0, "abc"), rawFrame(6, 0, "d"), rawFrame(4, 0, "e"))
+	rep, err := Check(bytes.NewReader(rec), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Frames != 3 || rep.Size != int64(len(rec)) {
+		t.Errorf("%d frames of %d bytes, want 3 of %d", rep.Frames, rep.Size, len(rec))
+	}
+	if len(rep.Problems) != 1 {
+		t.Fatalf("problems %v, want one", rep.Problems)
+	}
+	if p := rep.Problems[0]; p.Frame != 2 || p.Offset != 2*HeaderSize+4 {
+		t.Errorf("problem at frame %d offset %d, want frame 2 offset %d", p.Frame, p.Offset, 2*HeaderSize+4)
+	}
+}
@@ -0,0 +1,100 @@
+package format
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestClock(t *testing.T) {
+	const max = 1<<32 - 1
+	tests := []struct {
+		name    string
+		secs    []uint32
+		elapsed []time.Duration // in seconds
+		unix    []int64
+	}{
+		{
+			name:    "forward",
+			secs:    []uint32{1000, 1001, 1061},
+			elapsed: []time.Duration{0, 1, 61},
+			unix:    []int64{1000, 1001, 1061},
+		},
+		{
+			name:    "wrap between two frames",
+			secs:    []uint32{max - 15, 16},
+			elapsed: []time.Duration{0, 32},
+			unix:    []int64{max - 15, 1<<32 + 16},
+		},
+		{
+			name:    "monotonic across the wrap",
+			secs:    []uint32{max - 1, max, 0, 1, 2},
+			elapsed: []time.Duration{0, 1, 2, 3, 4},
+			unix:    []int64{max - 1, max, 1 << 32, 1<<32 + 1, 1<<32 + 2},
+		},
+		{
+			name:    "backwards jump",
+			secs:    []uint32{1000, 990, 995},
+			elapsed: []time.Duration{0, -10, -5},
+			unix:    []int64{1000, 990, 995},
+		},
+		{
+			name:    "backwards across the wrap",
+			secs:    []uint32{max, 1, max, 2},
+			elapsed: []time.Duration{0, 2, 0, 3},
+			unix:    []int64{max, 1<<32 + 1, max, 1<<32 + 2},
+		},
+		{
+			name:    "large jump below half the range",
+			secs:    []uint32{10, 1 << 31},
+			elapsed: []time.Duration{0, 1<<31 - 10},
+			unix:    []int64{10, 1 << 31},
+		},
+	}
+	for _, tt := range tests {
+		var rec []byte
+		for _, s := range tt.secs {
+			rec = append(rec, rawFrame(s, 250000, "x")...)
+		}
+		r := NewReader(bytes.NewReader(rec))
+		for i := range tt.secs {
+			f, err := r.ReadFrame()
+			if err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+			if want := tt.elapsed[i] * time.Second; f.Elapsed != want {
+				t.Errorf("%s: frame %d: elapsed %v, want %v", tt.name, i, f.Elapsed, want)
+			}
+			if f.Time.Unix() != tt.unix[i] || f.Time.Nanosecond() != 250000000 {
+				t.Errorf("%s: frame %d: time %d.%09d, want %d.250000000", tt.name, i, f.Time.Unix(), f.Time.Nanosecond(), tt.unix[i])
+			}
+		}
+	}
+}
+
+func TestIndexAfterWrap(t *testing.T) {
+	// ten frames 4s apart; the seconds wrap after the fourth
+	var rec []byte
+	for i := 0; i < 10; i++ {
+		rec = append(rec, rawFrame(uint32(1<<32-16+i*4), 0, string(rune('0'+i)))...)
+	}
+	idx, err := BuildIndex(bytes.NewReader(rec), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := idx.Duration(); d != 36*time.Second {
+		t.Fatalf("duration %v, want 36s", d)
+	}
+	if len(idx.Entries) != 4 {
+		t.Fatalf("%d entries, want 4", len(idx.Entries))
+	}
+	for i, e := range idx.Entries {
+		n := i * 3
+		if want := time.Duration(n*4) * time.Second; e.Time != want {
+			t.Errorf("entry %d: time %v, want %v", i, e.Time, want)
+		}
+		if want := int64(n * (HeaderSize + 1)); e.Offset != want {
+			t.Errorf("entry %d: offset %d, want %d", i, e.Offset, want)
+		}
+	}
+}
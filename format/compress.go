@@ -0,0 +1,74 @@
+package format
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"io"
+)
+
+var (
+	gzipMagic  = []byte{0x1f, 0x8b, 0x08} // ID1, ID2 and the deflate method
+	bzip2Magic = []byte("BZh")
+
+	// the signatures of the first bzip2 block and of an empty stream
+	bzip2Block = []byte{0x31, 0x41, 0x59, 0x26, 0x53, 0x59}
+	bzip2End   = []byte{0x17, 0x72, 0x45, 0x38, 0x50, 0x90}
+)
+
+// magicLen is how many bytes of a stream are looked at to detect its
+// compression.
+const magicLen = 10
+
+// isGzip reports whether magic starts a gzip stream. A plain recording
+// starts with the seconds of its first frame, whose low bytes can be the
+// gzip magic, so the flags which follow must also be valid. Their reserved
+// bits are the top bits of the seconds, which are only zero before 1987.
+func isGzip(magic []byte) bool {
+	return len(magic) > len(gzipMagic) && bytes.HasPrefix(magic, gzipMagic) && magic[3]&0xe0 == 0
+}
+
+// isBzip2 reports whether magic starts a bzip2 stream: "BZh", the block
+// size from 1 to 9 and the signature of a block or of the end.
+func isBzip2(magic []byte) bool {
+	if len(magic) < magicLen || !bytes.HasPrefix(magic, bzip2Magic) || magic[3] < '1' || magic[3] > '9' {
+		return false
+	}
+	return bytes.Equal(magic[4:], bzip2Block) || bytes.Equal(magic[4:], bzip2End)
+}
+
+// Decompress returns a reader yielding the uncompressed recording in r. Gzip
+// and bzip2 streams are detected by their magic bytes; anything else is
+// returned as is. A seekable r stays seekable when it is not compressed.
+func Decompress(r io.Reader) (io.Reader, error) {
+	var magic []byte
+	if rs, ok := r.(io.ReadSeeker); ok && seekable(rs) {
+		var b [magicLen]byte
+		n, err := io.ReadFull(rs, b[:])
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return nil, err
+		}
+		if _, err = rs.Seek(int64(-n), io.SeekCurrent); err != nil {
+			return nil, err
+		}
+		magic = b[:n]
+	} else {
+		br := bufio.NewReader(r)
+		magic, _ = br.Peek(magicLen)
+		r = br
+	}
+
+	switch {
+	case isGzip(magic):
+		return gzip.NewReader(r)
+	case isBzip2(magic):
+		return bzip2.NewReader(r), nil
+	}
+	return r, nil
+}
+
+func seekable(s io.Seeker) bool {
+	_, err := s.Seek(0, io.SeekCurrent)
+	return err == nil
+}
@@ -0,0 +1,123 @@
+package format
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var plainRecording = concat(rawFrame(1, 0, "hello"), rawFrame(2, 500000, "world"))
+
+// plainRecording compressed by bzip2 -9, which the standard library cannot
+// write.
+var bzip2Recording = []byte{
+	0x42, 0x5a, 0x68, 0x39, 0x31, 0x41, 0x59, 0x26, 0x53, 0x59, 0xba, 0x74,
+	0x56, 0x77, 0x00, 0x00, 0x07, 0x51, 0x90, 0x7a, 0x80, 0x40, 0x00, 0x06,
+	0x44, 0x90, 0x80, 0x20, 0x00, 0x20, 0x00, 0x31, 0x4c, 0x00, 0x01, 0x0c,
+	0xa6, 0x4d, 0x0c, 0x20, 0x05, 0x90, 0xaa, 0x38, 0x58, 0x93, 0x03, 0x05,
+	0x51, 0xbb, 0x64, 0xce, 0x3e, 0x7c, 0x5d, 0xc9, 0x14, 0xe1, 0x42, 0x42,
+	0xe9, 0xd1, 0x59, 0xdc,
+}
+
+// plainFrom returns an uncompressed recording whose first frame is at sec,
+// which starts the recording in little-endian.
+func plainFrom(sec uint32) []byte {
+	return concat(rawFrame(sec, 0, "hello"), rawFrame(sec+1, 0, "world"))
+}
+
+func gzipped(b []byte) []byte {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(b)
+	zw.Close()
+	return buf.Bytes()
+}
+
+func TestDecompress(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want []byte
+	}{
+		{"plain", plainRecording, plainRecording},
+		{"gzip", gzipped(plainRecording), plainRecording},
+		{"bzip2", bzip2Recording, plainRecording},
+		{"empty", nil, nil},
+		{"short", []byte{0x1f}, []byte{0x1f}},
+		{"plain starting 1f 8b", plainFrom(0x65438b1f), plainFrom(0x65438b1f)},
+		{"plain starting 1f 8b 08", plainFrom(0x5f088b1f), plainFrom(0x5f088b1f)},
+		{"plain starting BZh9", plainFrom(0x39685a42), plainFrom(0x39685a42)},
+	}
+	for _, tt := range tests {
+		// through a pipe-like reader and through a seekable one
+		for _, r := range []io.Reader{
+			io.MultiReader(bytes.NewReader(tt.data)),
+			bytes.NewReader(tt.data),
+		} {
+			dr, err := Decompress(r)
+			if err != nil {
+				t.Errorf("%s: %v", tt.name, err)
+				continue
+			}
+			got, err := io.ReadAll(dr)
+			if err != nil {
+				t.Errorf("%s: %v", tt.name, err)
+				continue
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("%s: got % x, want % x", tt.name, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestDecompressKeepsSeeking(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "rec.tty")
+	if err := os.WriteFile(name, plainRecording, 0644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	r, err := Decompress(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r != io.Reader(f) {
+		t.Fatalf("got a %T for an uncompressed file, want the file", r)
+	}
+	// the magic bytes read are given back
+	frame, err := NewReader(r).ReadFrame()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(frame.Data) != "hello" {
+		t.Errorf("read %q, want %q", frame.Data, "hello")
+	}
+}
+
+func TestDecompressedFrames(t *testing.T) {
+	for name, data := range map[string][]byte{
+		"gzip":  gzipped(plainRecording),
+		"bzip2": bzip2Recording,
+	} {
+		r, err := Decompress(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		idx, err := BuildIndex(r, 1)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(idx.Entries) != 2 || idx.Duration() != 1500*time.Millisecond {
+			t.Errorf("%s: %d frames over %v, want 2 over 1.5s", name, len(idx.Entries), idx.Duration())
+		}
+	}
+}
@@ -0,0 +1,153 @@
+// Package format implements reading and writing of ttyrec recordings.
+//
+// A recording is a sequence of frames. Each frame starts with a 12 byte
+// little-endian header (seconds, microseconds, length of payload) followed
+// by the payload written to the terminal.
+//
+// Seconds are stored as an unsigned 32-bit value, which overflows in 2106
+// and is read as negative by tools using a signed value after 2038.
+// Reader unwraps the counter when it overflows between two frames, so
+// Frame.Time keeps increasing, and reports Frame.Elapsed relative to the
+// first frame, which is what players and converters should use.
+package format
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"time"
+)
+
+// HeaderSize is the size of the header preceding each frame.
+const HeaderSize = 12
+
+// MaxFrameSize is the largest payload a frame is expected to carry. Larger
+// lengths in a header mean the recording is corrupt.
+const MaxFrameSize = 16 << 20
+
+// ErrFrameTooLarge is returned when a header claims a payload larger than
+// MaxFrameSize.
+var ErrFrameTooLarge = errors.New("format: frame too large")
+
+// Frame is a chunk of terminal output recorded at Time.
+type Frame struct {
+	Time    time.Time
+	Elapsed time.Duration // time since the first frame of the recording
+	Data    []byte
+}
+
+type header struct {
+	sec  uint32
+	usec uint32
+	len  uint32
+}
+
+func readHeader(r io.Reader) (header, error) {
+	var b [HeaderSize]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return header{}, err
+	}
+	return header{
+		sec:  binary.LittleEndian.Uint32(b[0:]),
+		usec: binary.LittleEndian.Uint32(b[4:]),
+		len:  binary.LittleEndian.Uint32(b[8:]),
+	}, nil
+}
+
+// clock converts the 32-bit seconds of successive headers into times,
+// assuming that the counter wrapped when it jumps by more than half its
+// range.
+type clock struct {
+	started bool
+	start   time.Time
+	epoch   int64
+	last    uint32
+}
+
+const wrap = 1 << 32
+
+func (c *clock) time(h header) time.Time {
+	if c.started {
+		switch {
+		case h.sec < c.last && c.last-h.sec > wrap/2:
+			c.epoch += wrap
+		case h.sec > c.last && h.sec-c.last > wrap/2 && c.epoch > 0:
+			c.epoch -= wrap
+		}
+	}
+	c.last = h.sec
+	t := time.Unix(c.epoch+int64(h.sec), int64(h.usec)*1000)
+	if !c.started {
+		c.started = true
+		c.start = t
+	}
+	return t
+}
+
+// Reader reads frames from a ttyrec stream.
+type Reader struct {
+	r     io.Reader
+	off   int64
+	clock clock
+}
+
+// NewReader returns a new Reader reading from r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: r}
+}
+
+// ReadFrame reads the next frame. It returns io.EOF when there are no more
+// frames, and io.ErrUnexpectedEOF when the stream ends in the middle of one.
+func (r *Reader) ReadFrame() (*Frame, error) {
+	h, err := readHeader(r.r)
+	if err != nil {
+		return nil, err
+	}
+	if h.len > MaxFrameSize {
+		return nil, ErrFrameTooLarge
+	}
+
+	data := make([]byte, h.len)
+	if _, err := io.ReadFull(r.r, data); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	r.off += HeaderSize + int64(h.len)
+	t := r.clock.time(h)
+	return &Frame{
+		Time:    t,
+		Elapsed: t.Sub(r.clock.start),
+		Data:    data,
+	}, nil
+}
+
+// Offset returns the byte offset of the frame returned by the next call to
+// ReadFrame.
+func (r *Reader) Offset() int64 {
+	return r.off
+}
+
+// Writer writes frames to a ttyrec stream.
+type Writer struct {
+	w io.Writer
+}
+
+// NewWriter returns a new Writer writing to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// WriteFrame writes f with a header encoding its time and length.
+func (w *Writer) WriteFrame(f *Frame) error {
+	var h [HeaderSize]byte
+	binary.LittleEndian.PutUint32(h[0:], uint32(f.Time.Unix()))
+	binary.LittleEndian.PutUint32(h[4:], uint32(f.Time.Nanosecond()/1000))
+	binary.LittleEndian.PutUint32(h[8:], uint32(len(f.Data)))
+	if _, err := w.w.Write(h[:]); err != nil {
+		return err
+	}
+	_, err := w.w.Write(f.Data)
+	return err
+}
@@ -0,0 +1,130 @@
+package format
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+	"time"
+)
+
+// rawFrame returns a frame as stored in a recording.
+func rawFrame(sec, usec uint32, data string) []byte {
+	b := make([]byte, HeaderSize, HeaderSize+len(data))
+	binary.LittleEndian.PutUint32(b[0:], sec)
+	binary.LittleEndian.PutUint32(b[4:], usec)
+	binary.LittleEndian.PutUint32(b[8:], uint32(len(data)))
+	return append(b, data...)
+}
+
+func TestWriteFrameHeader(t *testing.T) {
+	var buf bytes.Buffer
+	f := &Frame{
+		Time: time.Unix(0x01020304, 5000),
+		Data: []byte("hello"),
+	}
+	if err := NewWriter(&buf).WriteFrame(f); err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{
+		0x04, 0x03, 0x02, 0x01, // seconds
+		0x05, 0x00, 0x00, 0x00, // microseconds
+		0x05, 0x00, 0x00, 0x00, // length
+		'h', 'e', 'l', 'l', 'o',
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Fatalf("got % x, want % x", buf.Bytes(), want)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	start := time.Unix(1500000000, 250000000)
+	frames := []*Frame{
+		{Time: start, Data: []byte("$ ")},
+		{Time: start.Add(1500 * time.Millisecond), Data: []byte("ls\r\n")},
+		{Time: start.Add(2 * time.Second), Data: []byte{}},
+		{Time: start.Add(time.Minute), Data: bytes.Repeat([]byte{0xff}, 4096)},
+	}
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	for _, f := range frames {
+		if err := w.WriteFrame(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := NewReader(&buf)
+	for i, want := range frames {
+		f, err := r.ReadFrame()
+		if err != nil {
+			t.Fatalf("frame %d: %v", i, err)
+		}
+		if !f.Time.Equal(want.Time) {
+			t.Errorf("frame %d: time %v, want %v", i, f.Time, want.Time)
+		}
+		if e := want.Time.Sub(start); f.Elapsed != e {
+			t.Errorf("frame %d: elapsed %v, want %v", i, f.Elapsed, e)
+		}
+		if !bytes.Equal(f.Data, want.Data) {
+			t.Errorf("frame %d: data %q, want %q", i, f.Data, want.Data)
+		}
+	}
+	if _, err := r.ReadFrame(); err != io.EOF {
+		t.Fatalf("got %v after the last frame, want io.EOF", err)
+	}
+}
+
+func TestReadFrameErrors(t *testing.T) {
+	tooLarge := rawFrame(1, 0, "")
+	binary.LittleEndian.PutUint32(tooLarge[8:], MaxFrameSize+1)
+
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"empty", nil, io.EOF},
+		{"truncated header", rawFrame(1, 0, "abc")[:7], io.ErrUnexpectedEOF},
+		{"missing payload", rawFrame(1, 0, "abc")[:HeaderSize], io.ErrUnexpectedEOF},
+		{"truncated payload", rawFrame(1, 0, "abc")[:HeaderSize+2], io.ErrUnexpectedEOF},
+		{"too large", tooLarge, ErrFrameTooLarge},
+	}
+	for _, tt := range tests {
+		_, err := NewReader(bytes.NewReader(tt.data)).ReadFrame()
+		if err != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestOffset(t *testing.T) {
+	var rec []byte
+	var offsets []int64
+	for _, data := range []string{"a", "", "hello, world"} {
+		offsets = append(offsets, int64(len(rec)))
+		rec = append(rec, rawFrame(1, 0, data)...)
+	}
+
+	r := NewReader(bytes.NewReader(rec))
+	for i, want := range offsets {
+		if off := r.Offset(); off != want {
+			t.Errorf("before frame %d: offset %d, want %d", i, off, want)
+		}
+		if _, err := r.ReadFrame(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if off := r.Offset(); off != int64(len(rec)) {
+		t.Errorf("at the end: offset %d, want %d", off, len(rec))
+	}
+
+	// a failed read does not move the offset
+	r = NewReader(bytes.NewReader(rawFrame(1, 0, "abc")[:HeaderSize+1]))
+	if _, err := r.ReadFrame(); err == nil {
+		t.Fatal("read a truncated frame")
+	}
+	if off := r.Offset(); off != 0 {
+		t.Errorf("after a truncated frame: offset %d, want 0", off)
+	}
+}
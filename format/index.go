@@ -0,0 +1,236 @@
+package format
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"sort"
+	"time"
+)
+
+// DefaultEvery is the default number of frames between index entries.
+const DefaultEvery = 100
+
+var indexMagic = [8]byte{'t', 't', 'y', 'i', 'd', 'x', '0', '2'}
+
+// ErrStaleIndex is returned by LoadIndex when the sidecar file does not
+// match the recording.
+var ErrStaleIndex = errors.New("format: index does not match recording")
+
+var errCorruptIndex = errors.New("format: corrupt index file")
+
+// IndexEntry locates a frame in a recording.
+type IndexEntry struct {
+	Offset int64         // byte offset of the frame header
+	Time   time.Duration // time since the first frame
+}
+
+// Index records the length of a recording and the position of every Nth
+// frame, so that tools need not scan the whole file to find them.
+type Index struct {
+	Every    int
+	Size     int64     // size of the indexed stream in bytes
+	FileSize int64     // size of the recording file, which is smaller when compressed
+	ModTime  time.Time // modification time of the recording file
+	Start    time.Time // time of the first frame
+	End      time.Time // time of the last frame
+	Entries  []IndexEntry
+}
+
+// BuildIndex reads all frames from r and returns an index holding every
+// Nth of them. The first frame is always indexed. If a frame cannot be
+// read, as at the end of a truncated recording, BuildIndex returns the
+// index of the frames before it along with the error.
+func BuildIndex(r io.Reader, every int) (*Index, error) {
+	if every <= 0 {
+		every = DefaultEvery
+	}
+	idx := &Index{Every: every}
+	fr := NewReader(r)
+	for n := 0; ; n++ {
+		off := fr.Offset()
+		f, err := fr.ReadFrame()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			idx.Size = off
+			return idx, err
+		}
+		if n == 0 {
+			idx.Start = f.Time
+		}
+		idx.End = f.Time
+		if n%every == 0 {
+			idx.Entries = append(idx.Entries, IndexEntry{
+				Offset: off,
+				Time:   f.Elapsed,
+			})
+		}
+	}
+	idx.Size = fr.Offset()
+	return idx, nil
+}
+
+// Duration returns the time between the first and the last frame.
+func (idx *Index) Duration() time.Duration {
+	return idx.End.Sub(idx.Start)
+}
+
+// Lookup returns the last entry at or before d.
+func (idx *Index) Lookup(d time.Duration) IndexEntry {
+	i := sort.Search(len(idx.Entries), func(i int) bool {
+		return idx.Entries[i].Time > d
+	})
+	if i == 0 {
+		return IndexEntry{}
+	}
+	return idx.Entries[i-1]
+}
+
+type indexHeader struct {
+	Magic    [8]byte
+	Every    uint32
+	Count    uint32
+	Size     int64
+	FileSize int64
+	ModTime  int64
+	Start    int64
+	End      int64
+}
+
+// WriteTo writes idx in its binary sidecar form.
+func (idx *Index) WriteTo(w io.Writer) (int64, error) {
+	h := indexHeader{
+		Magic:    indexMagic,
+		Every:    uint32(idx.Every),
+		Count:    uint32(len(idx.Entries)),
+		Size:     idx.Size,
+		FileSize: idx.FileSize,
+		ModTime:  idx.ModTime.UnixNano(),
+		Start:    idx.Start.UnixNano(),
+		End:      idx.End.UnixNano(),
+	}
+	if err := binary.Write(w, binary.LittleEndian, &h); err != nil {
+		return 0, err
+	}
+	if err := binary.Write(w, binary.LittleEndian, idx.Entries); err != nil {
+		return 0, err
+	}
+	return int64(binary.Size(h) + binary.Size(idx.Entries)), nil
+}
+
+// ReadIndex reads an index written by WriteTo.
+func ReadIndex(r io.Reader) (*Index, error) {
+	var h indexHeader
+	if err := binary.Read(r, binary.LittleEndian, &h); err != nil {
+		return nil, err
+	}
+	if h.Magic != indexMagic {
+		return nil, errors.New("format: not an index file")
+	}
+	// every indexed frame takes at least a header in the stream, so a
+	// count the size cannot hold means the file is corrupt
+	if h.Every == 0 || h.Size < 0 || int64(h.Count) > (h.Size/HeaderSize+int64(h.Every)-1)/int64(h.Every) {
+		return nil, errCorruptIndex
+	}
+	idx := &Index{
+		Every:    int(h.Every),
+		Size:     h.Size,
+		FileSize: h.FileSize,
+		ModTime:  time.Unix(0, h.ModTime),
+		Start:    time.Unix(0, h.Start),
+		End:      time.Unix(0, h.End),
+	}
+	// read the entries in chunks rather than trusting the count with a
+	// single allocation
+	chunk := make([]IndexEntry, 1024)
+	for n := int(h.Count); n > 0; n -= len(chunk) {
+		if n < len(chunk) {
+			chunk = chunk[:n]
+		}
+		if err := binary.Read(r, binary.LittleEndian, chunk); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		idx.Entries = append(idx.Entries, chunk...)
+	}
+	for i, e := range idx.Entries {
+		if e.Offset < 0 || e.Offset >= h.Size || i > 0 && e.Offset <= idx.Entries[i-1].Offset {
+			return nil, errCorruptIndex
+		}
+	}
+	return idx, nil
+}
+
+// IndexFile returns the name of the sidecar index for the recording name.
+func IndexFile(name string) string {
+	return name + ".idx"
+}
+
+// LoadIndex reads the sidecar index of the recording name. It returns
+// ErrStaleIndex if the recording changed since the index was written.
+func LoadIndex(name string) (*Index, error) {
+	fi, err := os.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(IndexFile(name))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	idx, err := ReadIndex(bufio.NewReader(f))
+	if err != nil {
+		return nil, err
+	}
+	if idx.FileSize != fi.Size() || !idx.ModTime.Equal(fi.ModTime()) {
+		return nil, ErrStaleIndex
+	}
+	return idx, nil
+}
+
+// SaveIndex writes idx as the sidecar index of the recording name.
+func SaveIndex(name string, idx *Index) error {
+	fi, err := os.Stat(name)
+	if err != nil {
+		return err
+	}
+	idx.FileSize = fi.Size()
+	idx.ModTime = fi.ModTime()
+
+	f, err := os.Create(IndexFile(name))
+	if err != nil {
+		return err
+	}
+	if _, err = idx.WriteTo(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// IndexOf returns the index of the recording name, from its sidecar when
+// that is up to date and by reading the recording otherwise. A damaged
+// recording is indexed up to the frame which could not be read, and that
+// index is returned along with the error.
+func IndexOf(name string) (*Index, error) {
+	if idx, err := LoadIndex(name); err == nil {
+		return idx, nil
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r, err := Decompress(f)
+	if err != nil {
+		return nil, err
+	}
+	return BuildIndex(r, DefaultEvery)
+}
@@ -0,0 +1,249 @@
+package format
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// recording returns n frames, one every second from 1000s on, each holding
+// its number.
+func recording(n int) []byte {
+	var rec []byte
+	for i := 0; i < n; i++ {
+		rec = append(rec, rawFrame(uint32(1000+i), 0, strconv.Itoa(i))...)
+	}
+	return rec
+}
+
+func TestBuildIndex(t *testing.T) {
+	rec := recording(25)
+	idx, err := BuildIndex(bytes.NewReader(rec), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if idx.Size != int64(len(rec)) {
+		t.Errorf("size %d, want %d", idx.Size, len(rec))
+	}
+	if d := idx.Duration(); d != 24*time.Second {
+		t.Errorf("duration %v, want 24s", d)
+	}
+	if len(idx.Entries) != 3 {
+		t.Fatalf("%d entries, want 3", len(idx.Entries))
+	}
+	for i, e := range idx.Entries {
+		r := NewReader(bytes.NewReader(rec[e.Offset:]))
+		f, err := r.ReadFrame()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := strconv.Itoa(i * 10); string(f.Data) != want {
+			t.Errorf("entry %d points at frame %s, want %s", i, f.Data, want)
+		}
+		if want := time.Duration(i*10) * time.Second; e.Time != want {
+			t.Errorf("entry %d: time %v, want %v", i, e.Time, want)
+		}
+	}
+
+	tests := []struct {
+		d    time.Duration
+		want time.Duration
+	}{
+		{-time.Second, 0},
+		{0, 0},
+		{9 * time.Second, 0},
+		{10 * time.Second, 10 * time.Second},
+		{time.Hour, 20 * time.Second},
+	}
+	for _, tt := range tests {
+		if e := idx.Lookup(tt.d); e.Time != tt.want {
+			t.Errorf("Lookup(%v) = %v, want %v", tt.d, e.Time, tt.want)
+		}
+	}
+}
+
+func TestBuildIndexTruncated(t *testing.T) {
+	rec := recording(25)
+	rec = rec[:len(rec)-1]
+	idx, err := BuildIndex(bytes.NewReader(rec), 10)
+	if err != io.ErrUnexpectedEOF {
+		t.Fatalf("got %v, want io.ErrUnexpectedEOF", err)
+	}
+	if idx == nil {
+		t.Fatal("no index of the frames before the truncated one")
+	}
+	if d := idx.Duration(); d != 23*time.Second {
+		t.Errorf("duration %v, want 23s", d)
+	}
+	if len(idx.Entries) != 3 {
+		t.Errorf("%d entries, want 3", len(idx.Entries))
+	}
+	if want := int64(len(recording(24))); idx.Size != want {
+		t.Errorf("size %d, want %d", idx.Size, want)
+	}
+}
+
+func TestIndexRoundTrip(t *testing.T) {
+	idx, err := BuildIndex(bytes.NewReader(recording(25)), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx.FileSize = 1234
+	idx.ModTime = time.Unix(1600000000, 5)
+
+	var buf bytes.Buffer
+	n, err := idx.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(buf.Len()) {
+		t.Errorf("WriteTo returned %d, wrote %d bytes", n, buf.Len())
+	}
+	got, err := ReadIndex(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Every != idx.Every || got.Size != idx.Size || got.FileSize != idx.FileSize ||
+		!got.ModTime.Equal(idx.ModTime) || !got.Start.Equal(idx.Start) || !got.End.Equal(idx.End) {
+		t.Errorf("got %+v, want %+v", got, idx)
+	}
+	if len(got.Entries) != len(idx.Entries) {
+		t.Fatalf("%d entries, want %d", len(got.Entries), len(idx.Entries))
+	}
+	for i := range got.Entries {
+		if got.Entries[i] != idx.Entries[i] {
+			t.Errorf("entry %d: %+v, want %+v", i, got.Entries[i], idx.Entries[i])
+		}
+	}
+}
+
+func TestReadIndexCorrupt(t *testing.T) {
+	idx, err := BuildIndex(bytes.NewReader(recording(25)), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if _, err := idx.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	good := buf.Bytes()
+	countAt := len(indexMagic) + 4
+
+	corrupt := func(f func(b []byte) []byte) []byte {
+		return f(append([]byte(nil), good...))
+	}
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"bad magic", corrupt(func(b []byte) []byte { b[0] = 'x'; return b })},
+		{"huge count", corrupt(func(b []byte) []byte {
+			binary.LittleEndian.PutUint32(b[countAt:], 0xffffffff)
+			return b
+		})},
+		{"count beyond size", corrupt(func(b []byte) []byte {
+			binary.LittleEndian.PutUint32(b[countAt:], 4)
+			return b
+		})},
+		{"zero every", corrupt(func(b []byte) []byte {
+			binary.LittleEndian.PutUint32(b[len(indexMagic):], 0)
+			return b
+		})},
+		{"truncated entries", good[:len(good)-1]},
+		{"truncated header", good[:10]},
+	}
+	for _, tt := range tests {
+		if _, err := ReadIndex(bytes.NewReader(tt.data)); err == nil {
+			t.Errorf("%s: read a corrupt index", tt.name)
+		}
+	}
+}
+
+func TestLoadIndexStale(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "rec.tty")
+	if err := os.WriteFile(name, recording(25), 0644); err != nil {
+		t.Fatal(err)
+	}
+	idx, err := BuildIndex(bytes.NewReader(recording(25)), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveIndex(name, idx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadIndex(name); err != nil {
+		t.Fatalf("fresh index: %v", err)
+	}
+
+	// same size, newer file
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(name, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadIndex(name); err != ErrStaleIndex {
+		t.Errorf("after touching the recording: got %v, want ErrStaleIndex", err)
+	}
+
+	if err := SaveIndex(name, idx); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.OpenFile(name, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write(rawFrame(2000, 0, "more"))
+	f.Close()
+	if err := os.Chtimes(name, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadIndex(name); err != ErrStaleIndex {
+		t.Errorf("after appending to the recording: got %v, want ErrStaleIndex", err)
+	}
+}
+
+func TestIndexOf(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "rec.tty")
+	if err := os.WriteFile(name, recording(25), 0644); err != nil {
+		t.Fatal(err)
+	}
+	idx, err := IndexOf(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := idx.Duration(); d != 24*time.Second {
+		t.Errorf("without a sidecar: duration %v, want 24s", d)
+	}
+
+	// the sidecar is used while it is up to date
+	saved := *idx
+	saved.End = saved.End.Add(time.Hour)
+	if err := SaveIndex(name, &saved); err != nil {
+		t.Fatal(err)
+	}
+	if idx, err = IndexOf(name); err != nil || idx.Duration() != saved.Duration() {
+		t.Errorf("with a sidecar: duration %v (%v), want %v", idx.Duration(), err, saved.Duration())
+	}
+
+	damaged := filepath.Join(dir, "damaged.tty")
+	rec := recording(25)
+	if err := os.WriteFile(damaged, rec[:len(rec)-1], 0644); err != nil {
+		t.Fatal(err)
+	}
+	idx, err = IndexOf(damaged)
+	if err == nil {
+		t.Error("indexed a damaged recording without an error")
+	}
+	if idx == nil || idx.Duration() != 23*time.Second {
+		t.Errorf("damaged recording: got %+v, want a duration of 23s", idx)
+	}
+
+	if idx, err := IndexOf(filepath.Join(dir, "missing")); idx != nil || err == nil {
+		t.Errorf("missing recording: got %+v, %v", idx, err)
+	}
+}
@@ -0,0 +1,45 @@
+package format
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+)
+
+// Meta describes the session a recording was made from. The ttyrec format
+// has no room for it, so it is kept in a JSON sidecar next to the recording.
+type Meta struct {
+	Width    int       `json:"width,omitempty"`
+	Height   int       `json:"height,omitempty"`
+	Command  string    `json:"command,omitempty"`
+	Title    string    `json:"title,omitempty"`
+	Encoding string    `json:"encoding,omitempty"`
+	Start    time.Time `json:"start"`
+}
+
+// MetaFile returns the name of the metadata sidecar for the recording name.
+func MetaFile(name string) string {
+	return name + ".json"
+}
+
+// LoadMeta reads the metadata sidecar of the recording name.
+func LoadMeta(name string) (*Meta, error) {
+	b, err := os.ReadFile(MetaFile(name))
+	if err != nil {
+		return nil, err
+	}
+	var m Meta
+	if err = json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// SaveMeta writes m as the metadata sidecar of the recording name.
+func SaveMeta(name string, m *Meta) error {
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(MetaFile(name), append(b, '\n'), 0644)
+}
@@ -0,0 +1,64 @@
+package format
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMetaRoundTrip(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "rec.tty")
+	want := &Meta{
+		Width:    120,
+		Height:   40,
+		Command:  `cmd.exe /k "echo hi"`,
+		Title:    "build log",
+		Encoding: "shift_jis",
+		Start:    time.Date(2021, 3, 4, 5, 6, 7, 890000000, time.FixedZone("JST", 9*60*60)),
+	}
+	if err := SaveMeta(name, want); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(MetaFile(name)); err != nil {
+		t.Fatal(err)
+	}
+	got, err := LoadMeta(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Start.Equal(want.Start) {
+		t.Errorf("start %v, want %v", got.Start, want.Start)
+	}
+	got.Start = want.Start
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestLoadMetaMissing(t *testing.T) {
+	m, err := LoadMeta(filepath.Join(t.TempDir(), "rec.tty"))
+	if !os.IsNotExist(err) {
+		t.Errorf("got %v, want a missing file error", err)
+	}
+	if m != nil {
+		t.Errorf("got %+v for a missing sidecar", m)
+	}
+}
+
+func TestLoadMetaCorrupt(t *testing.T) {
+	for _, data := range []string{"", "{", `{"width": "wide"}`, "\x00\x01"} {
+		name := filepath.Join(t.TempDir(), "rec.tty")
+		if err := os.WriteFile(MetaFile(name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		m, err := LoadMeta(name)
+		if err == nil {
+			t.Errorf("%q: loaded %+v", data, m)
+		}
+		if m != nil {
+			t.Errorf("%q: got %+v along with %v", data, m, err)
+		}
+	}
+}
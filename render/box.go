@@ -0,0 +1,120 @@
+package render
+
+import (
+	"image"
+)
+
+// arms of a box drawing character, joining the middle of the cell to its
+// edges
+const (
+	up = 1 << iota
+	down
+	left
+	right
+	heavy // heavy and double lines are drawn two pixels wide
+)
+
+var boxes = map[rune]uint8{
+	'─': left | right, '━': left | right | heavy,
+	'│': up | down, '┃': up | down | heavy,
+	'┄': left | right, '┅': left | right | heavy,
+	'┆': up | down, '┇': up | down | heavy,
+	'┈': left | right, '┉': left | right | heavy,
+	'┊': up | down, '┋': up | down | heavy,
+	'┌': down | right, '┏': down | right | heavy,
+	'┐': down | left, '┓': down | left | heavy,
+	'└': up | right, '┗': up | right | heavy,
+	'┘': up | left, '┛': up | left | heavy,
+	'├': up | down | right, '┣': up | down | right | heavy,
+	'┤': up | down | left, '┫': up | down | left | heavy,
+	'┬': down | left | right, '┳': down | left | right | heavy,
+	'┴': up | left | right, '┻': up | left | right | heavy,
+	'┼': up | down | left | right, '╋': up | down | left | right | heavy,
+	'═': left | right | heavy, '║': up | down | heavy,
+	'╔': down | right | heavy, '╗': down | left | heavy,
+	'╚': up | right | heavy, '╝': up | left | heavy,
+	'╠': up | down | right | heavy, '╣': up | down | left | heavy,
+	'╦': down | left | right | heavy, '╩': up | left | right | heavy,
+	'╬': up | down | left | right | heavy,
+	'╭': down | right, '╮': down | left,
+	'╯': up | left, '╰': up | right,
+	'╴': left, '╵': up, '╶': right, '╷': down,
+}
+
+// drawSpecial draws the box drawing, block and scan line characters,
+// which the font lacks, with lines and rectangles. It reports whether ch
+// was one of them.
+func drawSpecial(img *image.Paletted, r image.Rectangle, ch rune, fg uint8) bool {
+	if arms, ok := boxes[ch]; ok {
+		t := 1
+		if arms&heavy != 0 {
+			t = 2
+		}
+		cx := r.Min.X + CellWidth/2
+		cy := r.Min.Y + CellHeight/2
+		if arms&up != 0 {
+			fill(img, image.Rect(cx, r.Min.Y, cx+t, cy+t), fg)
+		}
+		if arms&down != 0 {
+			fill(img, image.Rect(cx, cy, cx+t, r.Max.Y), fg)
+		}
+		if arms&left != 0 {
+			fill(img, image.Rect(r.Min.X, cy, cx+t, cy+t), fg)
+		}
+		if arms&right != 0 {
+			fill(img, image.Rect(cx, cy, r.Max.X, cy+t), fg)
+		}
+		return true
+	}
+
+	mx := r.Min.X + CellWidth/2
+	my := r.Min.Y + CellHeight/2
+	switch ch {
+	case '█':
+		fill(img, r, fg)
+	case '▀':
+		fill(img, image.Rect(r.Min.X, r.Min.Y, r.Max.X, my), fg)
+	case '▄':
+		fill(img, image.Rect(r.Min.X, my, r.Max.X, r.Max.Y), fg)
+	case '▌':
+		fill(img, image.Rect(r.Min.X, r.Min.Y, mx, r.Max.Y), fg)
+	case '▐':
+		fill(img, image.Rect(mx, r.Min.Y, r.Max.X, r.Max.Y), fg)
+	case '░', '▒', '▓':
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				var on bool
+				switch ch {
+				case '░':
+					on = x%2 == 0 && y%2 == 0
+				case '▒':
+					on = (x+y)%2 == 0
+				case '▓':
+					on = x%2 == 0 || y%2 == 0
+				}
+				if on {
+					fill(img, image.Rect(x, y, x+1, y+1), fg)
+				}
+			}
+		}
+	case '⎺', '⎻', '⎼', '⎽':
+		// scan lines 1, 3, 7 and 9 of the DEC special graphics
+		y := r.Min.Y + int(ch-'⎺')*(CellHeight-1)/3
+		fill(img, image.Rect(r.Min.X, y, r.Max.X, y+1), fg)
+	case '◆':
+		for dy := -3; dy <= 3; dy++ {
+			w := 3 - abs(dy)
+			fill(img, image.Rect(mx-w, my+dy, mx+w+1, my+dy+1), fg)
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
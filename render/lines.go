@@ -0,0 +1,62 @@
+package render
+
+import "github.com/mattn/ttyrec4windows/screen"
+
+// LineSet keeps every distinct line of an animation once, so that frames
+// share the lines which did not change. The zero value is ready to use.
+type LineSet struct {
+	Lines []string
+
+	ids map[string]int
+}
+
+// Add returns the index of line in Lines, adding it if it is new.
+func (ls *LineSet) Add(line string) int {
+	if ls.ids == nil {
+		ls.ids = map[string]int{}
+	}
+	id, ok := ls.ids[line]
+	if !ok {
+		id = len(ls.Lines)
+		ls.ids[line] = id
+		ls.Lines = append(ls.Lines, line)
+	}
+	return id
+}
+
+// Snapshot returns the lines of s as indexes in Lines, with -1 for blank
+// lines. Each line is converted by conv, which gets the column of the
+// cursor on the line it is on when cursor is set and the program has not
+// hidden it, and -1 otherwise, and returns "" for a blank line.
+func (ls *LineSet) Snapshot(s *screen.Screen, cursor bool, conv func(cells []screen.Cell, cursor int) string) []int {
+	_, h := s.Size()
+	cx, cy, visible := s.Cursor()
+	if !cursor || !visible {
+		cy = -1
+	}
+	lines := make([]int, h)
+	for y := range lines {
+		x := -1
+		if y == cy {
+			x = cx
+		}
+		lines[y] = -1
+		if l := conv(s.Line(y), x); l != "" {
+			lines[y] = ls.Add(l)
+		}
+	}
+	return lines
+}
+
+// SameLines reports whether two snapshots show the same lines.
+func SameLines(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,67 @@
+package render
+
+import (
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+)
+
+// Set changes colors of p from a comma separated list of name=#rrggbb
+// pairs, where the name is fg, bg or the number of a basic color, 0 to 15.
+// It lets a Palette be used as a flag.Value.
+func (p *Palette) Set(spec string) error {
+	for _, kv := range strings.Split(spec, ",") {
+		kv = strings.TrimSpace(kv)
+		if kv == "" {
+			continue
+		}
+		i := strings.IndexByte(kv, '=')
+		if i < 0 {
+			return fmt.Errorf("palette: %q is not name=#rrggbb", kv)
+		}
+		c, err := parseColor(kv[i+1:])
+		if err != nil {
+			return err
+		}
+		switch name := kv[:i]; name {
+		case "fg":
+			p.Foreground = c
+		case "bg":
+			p.Background = c
+		default:
+			n, err := strconv.Atoi(name)
+			if err != nil || n < 0 || n >= len(p.Colors) {
+				return fmt.Errorf("palette: unknown color name %q", name)
+			}
+			p.Colors[n] = c
+		}
+	}
+	return nil
+}
+
+// String returns the colors of p which differ from DefaultPalette, in the
+// form accepted by Set.
+func (p *Palette) String() string {
+	var s []string
+	if p.Foreground != DefaultPalette.Foreground {
+		s = append(s, "fg="+Hex(p.Foreground))
+	}
+	if p.Background != DefaultPalette.Background {
+		s = append(s, "bg="+Hex(p.Background))
+	}
+	for i, c := range p.Colors {
+		if c != DefaultPalette.Colors[i] {
+			s = append(s, fmt.Sprintf("%d=%s", i, Hex(c)))
+		}
+	}
+	return strings.Join(s, ",")
+}
+
+func parseColor(s string) (color.RGBA, error) {
+	v, err := strconv.ParseUint(strings.TrimPrefix(s, "#"), 16, 32)
+	if err != nil || len(strings.TrimPrefix(s, "#")) != 6 {
+		return color.RGBA{}, fmt.Errorf("palette: bad color %q", s)
+	}
+	return color.RGBA{uint8(v >> 16), uint8(v >> 8), uint8(v), 255}, nil
+}
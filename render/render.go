@@ -0,0 +1,242 @@
+// Package render draws a virtual screen into an image with a built-in
+// bitmap font, for the exporters which produce pictures of a recording.
+package render
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+
+	"github.com/mattn/ttyrec4windows/screen"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/text/unicode/norm"
+)
+
+// CellWidth and CellHeight are the size of a character cell in pixels.
+const (
+	CellWidth  = 7
+	CellHeight = 13
+)
+
+// indices of the default colors in the image palette, after the 16 basic
+// colors
+const (
+	fgIndex = 16
+	bgIndex = 17
+)
+
+var face = basicfont.Face7x13
+
+var fontMask = face.Mask.(*image.Alpha)
+
+// Palette is the set of colors an image is drawn with: the 16 basic
+// colors and the default foreground and background. Other colors are
+// drawn with the nearest basic color.
+type Palette struct {
+	Colors     [16]color.RGBA
+	Foreground color.RGBA
+	Background color.RGBA
+}
+
+// DefaultPalette is the xterm palette, light gray on black.
+var DefaultPalette = Palette{
+	Colors: [16]color.RGBA{
+		{0, 0, 0, 255}, {205, 0, 0, 255}, {0, 205, 0, 255}, {205, 205, 0, 255},
+		{0, 0, 238, 255}, {205, 0, 205, 255}, {0, 205, 205, 255}, {229, 229, 229, 255},
+		{127, 127, 127, 255}, {255, 0, 0, 255}, {0, 255, 0, 255}, {255, 255, 0, 255},
+		{92, 92, 255, 255}, {255, 0, 255, 255}, {0, 255, 255, 255}, {255, 255, 255, 255},
+	},
+	Foreground: color.RGBA{229, 229, 229, 255},
+	Background: color.RGBA{0, 0, 0, 255},
+}
+
+// ColorPalette returns the palette of the images drawn with p.
+func (p *Palette) ColorPalette() color.Palette {
+	cp := make(color.Palette, 0, 18)
+	for _, c := range p.Colors {
+		cp = append(cp, c)
+	}
+	return append(cp, p.Foreground, p.Background)
+}
+
+// RGBA returns the color c is drawn with by outputs which are not limited
+// to the palette: the palette color for the 16 basic colors, with bold
+// brightening the first 8, and c itself for the others. It returns false
+// for the default color.
+func (p *Palette) RGBA(c screen.Color, bold bool) (color.RGBA, bool) {
+	if n, ok := c.Index(); ok && n < 16 {
+		if bold && n < 8 {
+			n += 8
+		}
+		return p.Colors[n], true
+	}
+	if r, g, b, ok := c.RGB(); ok {
+		return color.RGBA{r, g, b, 255}, true
+	}
+	return color.RGBA{}, false
+}
+
+// Hex returns c as #rrggbb, for outputs using CSS or SVG colors.
+func Hex(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// NewImage returns an image the size of s filled with the background of p.
+func NewImage(s *screen.Screen, p *Palette) *image.Paletted {
+	w, h := s.Size()
+	return NewCanvas(image.Rect(0, 0, w*CellWidth, h*CellHeight), p)
+}
+
+// NewCanvas returns an image of the given bounds filled with the
+// background of p, to compose images returned by NewImage.
+func NewCanvas(r image.Rectangle, p *Palette) *image.Paletted {
+	img := image.NewPaletted(r, p.ColorPalette())
+	fill(img, img.Rect, bgIndex)
+	return img
+}
+
+// Draw draws the cells of s into img, which must have been returned by
+// NewImage for a screen of the same size. The cursor is drawn as a block
+// when cursor is set and the program has not hidden it.
+func Draw(img *image.Paletted, s *screen.Screen, cursor bool) {
+	w, h := s.Size()
+	cx, cy, visible := s.Cursor()
+	cursor = cursor && visible
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := s.Cell(x, y)
+			if c.Rune == 0 {
+				if x > 0 && s.Cell(x-1, y).Wide {
+					// continuation of a wide character, drawn with it
+					continue
+				}
+				c.Rune = ' '
+			}
+			cw := 1
+			if c.Wide && x+1 < w {
+				cw = 2
+			}
+			r := image.Rect(x*CellWidth, y*CellHeight, (x+cw)*CellWidth, (y+1)*CellHeight)
+			fg, bg := colors(c)
+			if cursor && cx == x && cy == y {
+				fg, bg = bg, fg
+			}
+			fill(img, r, bg)
+			if c.Attr&screen.Invisible == 0 {
+				drawCell(img, r, c, fg)
+			}
+		}
+	}
+}
+
+// DrawString draws text in the default foreground color, with the top
+// left corner of its first cell at pt. It is meant for captions.
+func DrawString(img *image.Paletted, pt image.Point, text string) {
+	for _, ch := range text {
+		r := image.Rect(pt.X, pt.Y, pt.X+CellWidth, pt.Y+CellHeight)
+		drawGlyph(img, r, ch, false, fgIndex)
+		pt.X += CellWidth
+	}
+}
+
+// colors returns the palette indices of the foreground and background of c.
+func colors(c screen.Cell) (fg, bg uint8) {
+	fg, bg = fgIndex, bgIndex
+	if n := c.FG.Nearest16(); n >= 0 {
+		fg = uint8(n)
+	}
+	if n := c.BG.Nearest16(); n >= 0 {
+		bg = uint8(n)
+	}
+	if c.Attr&screen.Bold != 0 && fg < 8 {
+		fg += 8
+	}
+	if c.Attr&screen.Reverse != 0 {
+		fg, bg = bg, fg
+	}
+	return fg, bg
+}
+
+func drawCell(img *image.Paletted, r image.Rectangle, c screen.Cell, fg uint8) {
+	ch := c.Rune
+	if c.Comb != "" {
+		if p := []rune(norm.NFC.String(c.String())); len(p) == 1 {
+			ch = p[0]
+		}
+	}
+	if !drawSpecial(img, r, ch, fg) {
+		drawGlyph(img, r, ch, c.Wide, fg)
+		if c.Attr&screen.Bold != 0 {
+			drawGlyph(img, r.Add(image.Pt(1, 0)), ch, c.Wide, fg)
+		}
+	}
+	if c.Attr&screen.Underline != 0 {
+		y := r.Min.Y + face.Ascent + 1
+		fill(img, image.Rect(r.Min.X, y, r.Max.X, y+1), fg)
+	}
+	if c.Attr&screen.Strikethrough != 0 {
+		y := r.Min.Y + CellHeight/2
+		fill(img, image.Rect(r.Min.X, y, r.Max.X, y+1), fg)
+	}
+}
+
+// drawGlyph draws ch from the font into r. The font only has ASCII, so
+// other characters are drawn as their unaccented letter or a look-alike,
+// and wide characters as an empty box.
+func drawGlyph(img *image.Paletted, r image.Rectangle, ch rune, wide bool, fg uint8) {
+	ch = fallback(ch)
+	dot := fixed.P(r.Min.X, r.Min.Y+face.Ascent)
+	dr, _, mp, _, ok := face.Glyph(dot, ch)
+	if !ok && wide {
+		box := r.Inset(2)
+		fill(img, image.Rect(box.Min.X, box.Min.Y, box.Max.X, box.Min.Y+1), fg)
+		fill(img, image.Rect(box.Min.X, box.Max.Y-1, box.Max.X, box.Max.Y), fg)
+		fill(img, image.Rect(box.Min.X, box.Min.Y, box.Min.X+1, box.Max.Y), fg)
+		fill(img, image.Rect(box.Max.X-1, box.Min.Y, box.Max.X, box.Max.Y), fg)
+		return
+	}
+	b := img.Rect
+	for y := dr.Min.Y; y < dr.Max.Y; y++ {
+		for x := dr.Min.X; x < dr.Max.X; x++ {
+			if !image.Pt(x, y).In(b) {
+				continue
+			}
+			if fontMask.AlphaAt(mp.X+x-dr.Min.X, mp.Y+y-dr.Min.Y).A >= 0x80 {
+				img.Pix[img.PixOffset(x, y)] = fg
+			}
+		}
+	}
+}
+
+// lookalikes maps common typographic characters to ASCII.
+var lookalikes = map[rune]rune{
+	'‘': '\'', '’': '\'', '“': '"', '”': '"',
+	'–': '-', '—': '-', '−': '-', '•': '*', '·': '.', '×': 'x',
+	'\u00a0': ' ',
+}
+
+func fallback(ch rune) rune {
+	if ch < 0x80 {
+		return ch
+	}
+	if r, ok := lookalikes[ch]; ok {
+		return r
+	}
+	if d := norm.NFD.String(string(ch)); d[0] < 0x80 {
+		return rune(d[0])
+	}
+	return ch
+}
+
+// fill paints r with the palette color at index c.
+func fill(img *image.Paletted, r image.Rectangle, c uint8) {
+	r = r.Intersect(img.Rect)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		i := img.PixOffset(r.Min.X, y)
+		row := img.Pix[i : i+r.Dx()]
+		for x := range row {
+			row[x] = c
+		}
+	}
+}
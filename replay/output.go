@@ -0,0 +1,40 @@
+package replay
+
+import (
+	"bufio"
+	"os"
+)
+
+// Output is the buffered file an exporter writes to.
+type Output struct {
+	*bufio.Writer
+
+	f *os.File
+}
+
+// Create creates the file name for an exporter to write to. An empty name
+// writes to standard output.
+func Create(name string) (*Output, error) {
+	f := os.Stdout
+	if name != "" {
+		var err error
+		if f, err = os.Create(name); err != nil {
+			return nil, err
+		}
+	}
+	return &Output{Writer: bufio.NewWriter(f), f: f}, nil
+}
+
+// Close flushes the output and closes the file created by Create. The
+// output is only complete once Close succeeds. Standard output is left
+// open.
+func (o *Output) Close() error {
+	err := o.Flush()
+	if o.f == os.Stdout {
+		return err
+	}
+	if cerr := o.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
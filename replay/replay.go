@@ -0,0 +1,197 @@
+// Package replay plays a recording into a virtual screen frame by frame,
+// with the speed and idle time adjustments shared by the exporters.
+package replay
+
+import (
+	"errors"
+	"io"
+	"os"
+	"time"
+
+	enc "github.com/mattn/go-encoding"
+	"github.com/mattn/ttyrec4windows/format"
+	"github.com/mattn/ttyrec4windows/screen"
+	"golang.org/x/text/transform"
+)
+
+// ErrUnknownEncoding is returned for an encoding name which is not known.
+var ErrUnknownEncoding = errors.New("unknown encoding name")
+
+// Options controls how a recording is replayed. Zero values select the
+// defaults.
+type Options struct {
+	// Width and Height are the screen size. The default is the size in
+	// the metadata, or 80x24.
+	Width, Height int
+	// Encoding of the recorded output. The default is the encoding in
+	// the metadata, or UTF-8.
+	Encoding string
+	// Speed divides the time between frames. The default is 1.
+	Speed float64
+	// MaxIdle caps the time between frames, after Speed is applied.
+	// Zero keeps the recorded pauses.
+	MaxIdle time.Duration
+}
+
+// Player replays the frames of a recording into Screen.
+type Player struct {
+	Screen *screen.Screen
+	// Meta is the metadata of the recording, or nil.
+	Meta *format.Meta
+
+	c       io.Closer
+	r       *format.Reader
+	w       *transform.Writer
+	opt     Options
+	t       time.Duration
+	last    time.Duration
+	started bool
+	pending *format.Frame
+}
+
+// New returns a Player for the recording read from r. meta may be nil.
+func New(r io.Reader, meta *format.Meta, opt *Options) (*Player, error) {
+	p := &Player{Meta: meta, r: format.NewReader(r)}
+	if opt != nil {
+		p.opt = *opt
+	}
+	if meta != nil {
+		if p.opt.Width <= 0 {
+			p.opt.Width = meta.Width
+		}
+		if p.opt.Height <= 0 {
+			p.opt.Height = meta.Height
+		}
+		if p.opt.Encoding == "" {
+			p.opt.Encoding = meta.Encoding
+		}
+	}
+	if p.opt.Width <= 0 {
+		p.opt.Width = 80
+	}
+	if p.opt.Height <= 0 {
+		p.opt.Height = 24
+	}
+	if p.opt.Encoding == "" {
+		p.opt.Encoding = "utf-8"
+	}
+	if p.opt.Speed <= 0 {
+		p.opt.Speed = 1
+	}
+
+	e := enc.GetEncoding(p.opt.Encoding)
+	if e == nil {
+		return nil, ErrUnknownEncoding
+	}
+	p.Screen = screen.New(p.opt.Width, p.opt.Height)
+	p.w = transform.NewWriter(p.Screen, e.NewDecoder())
+	return p, nil
+}
+
+// Open returns a Player for the recording in the file name, which may be
+// compressed, with the metadata saved next to it. An empty name reads the
+// recording from standard input. The Player must be closed.
+func Open(name string, opt *Options) (*Player, error) {
+	if name == "" {
+		r, err := format.Decompress(os.Stdin)
+		if err != nil {
+			return nil, err
+		}
+		return New(r, nil, opt)
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	r, err := format.Decompress(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	meta, _ := format.LoadMeta(name)
+	p, err := New(r, meta, opt)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	p.c = f
+	return p, nil
+}
+
+// Close closes the file opened by Open.
+func (p *Player) Close() error {
+	if p.c == nil {
+		return nil
+	}
+	return p.c.Close()
+}
+
+// Next applies the next frame to the screen and returns the time it is
+// shown at, counted from the first frame with the speed and idle time
+// adjustments. It returns io.EOF after the last frame.
+func (p *Player) Next() (time.Duration, error) {
+	f, err := p.read()
+	if err != nil {
+		return p.t, err
+	}
+	p.t = p.when(f)
+	return p.t, p.apply(f)
+}
+
+// Seek applies the frames shown up to t, so that the screen is the one
+// shown at t. It cannot go back. It returns io.EOF when the recording
+// ends before t, leaving the last screen.
+func (p *Player) Seek(t time.Duration) error {
+	for {
+		f, err := p.read()
+		if err != nil {
+			return err
+		}
+		if p.when(f) > t {
+			p.pending = f
+			return nil
+		}
+		p.t = p.when(f)
+		if err = p.apply(f); err != nil {
+			return err
+		}
+	}
+}
+
+// Time returns the time of the screen, the time the last frame applied is
+// shown at.
+func (p *Player) Time() time.Duration {
+	return p.t
+}
+
+func (p *Player) read() (*format.Frame, error) {
+	if f := p.pending; f != nil {
+		p.pending = nil
+		return f, nil
+	}
+	return p.r.ReadFrame()
+}
+
+// when returns the time f is shown at.
+func (p *Player) when(f *format.Frame) time.Duration {
+	if !p.started {
+		return 0
+	}
+	d := time.Duration(float64(f.Elapsed-p.last) / p.opt.Speed)
+	// the clock may have been set back while recording, but the frames
+	// are still shown in order
+	if d < 0 {
+		d = 0
+	}
+	if p.opt.MaxIdle > 0 && d > p.opt.MaxIdle {
+		d = p.opt.MaxIdle
+	}
+	return p.t + d
+}
+
+func (p *Player) apply(f *format.Frame) error {
+	p.last = f.Elapsed
+	p.started = true
+	_, err := p.w.Write(f.Data)
+	return err
+}
@@ -0,0 +1,120 @@
+package replay
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/mattn/ttyrec4windows/format"
+)
+
+// recording has a long pause after b and its clock is set back before e.
+func recording(t *testing.T) *bytes.Buffer {
+	start := time.Unix(1000, 0)
+	var rec bytes.Buffer
+	w := format.NewWriter(&rec)
+	for _, f := range []struct {
+		elapsed time.Duration
+		data    string
+	}{
+		{0, "a"},
+		{time.Second, "b"},
+		{11 * time.Second, "c"},
+		{12 * time.Second, "d"},
+		{11500 * time.Millisecond, "e"},
+	} {
+		if err := w.WriteFrame(&format.Frame{Time: start.Add(f.elapsed), Data: []byte(f.data)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &rec
+}
+
+func TestNext(t *testing.T) {
+	tests := []struct {
+		name string
+		opt  Options
+		want []time.Duration
+	}{
+		{
+			name: "recorded",
+			want: []time.Duration{0, time.Second, 11 * time.Second, 12 * time.Second, 12 * time.Second},
+		},
+		{
+			name: "speed",
+			opt:  Options{Speed: 2},
+			want: []time.Duration{0, 500 * time.Millisecond, 5500 * time.Millisecond, 6 * time.Second, 6 * time.Second},
+		},
+		{
+			name: "max idle",
+			opt:  Options{MaxIdle: 2 * time.Second},
+			want: []time.Duration{0, time.Second, 3 * time.Second, 4 * time.Second, 4 * time.Second},
+		},
+		{
+			name: "speed and max idle",
+			opt:  Options{Speed: 2, MaxIdle: 2 * time.Second},
+			want: []time.Duration{0, 500 * time.Millisecond, 2500 * time.Millisecond, 3 * time.Second, 3 * time.Second},
+		},
+	}
+	for _, test := range tests {
+		p, err := New(recording(t), nil, &test.opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, want := range test.want {
+			got, err := p.Next()
+			if err != nil {
+				t.Fatalf("%s: frame %d: %v", test.name, i, err)
+			}
+			if got != want {
+				t.Errorf("%s: frame %d at %v, want %v", test.name, i, got, want)
+			}
+			if text := p.Screen.Text(0); text != "abcde"[:i+1] {
+				t.Errorf("%s: frame %d shows %q, want %q", test.name, i, text, "abcde"[:i+1])
+			}
+		}
+		if _, err := p.Next(); err != io.EOF {
+			t.Errorf("%s: got %v after the last frame, want EOF", test.name, err)
+		}
+	}
+}
+
+func TestSeek(t *testing.T) {
+	p, err := New(recording(t), nil, &Options{Speed: 2, MaxIdle: 2 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = p.Seek(2 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := p.Time(), 500*time.Millisecond; got != want {
+		t.Errorf("time after seeking to 2s is %v, want %v", got, want)
+	}
+	if got := p.Screen.Text(0); got != "ab" {
+		t.Errorf("screen after seeking to 2s shows %q, want %q", got, "ab")
+	}
+
+	// the frame after the one seeked to is not skipped
+	d, err := p.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := 2500 * time.Millisecond; d != want {
+		t.Errorf("next frame at %v, want %v", d, want)
+	}
+	if got := p.Screen.Text(0); got != "abc" {
+		t.Errorf("next frame shows %q, want %q", got, "abc")
+	}
+
+	if err = p.Seek(time.Hour); err != io.EOF {
+		t.Errorf("seeking past the end returned %v, want EOF", err)
+	}
+	if got, want := p.Time(), 3*time.Second; got != want {
+		t.Errorf("time after seeking past the end is %v, want %v", got, want)
+	}
+	if got := p.Screen.Text(0); got != "abcde" {
+		t.Errorf("screen after seeking past the end shows %q, want %q", got, "abcde")
+	}
+}
@@ -0,0 +1,86 @@
+package screen
+
+// charset is a character set which can be designated into G0 to G3.
+type charset uint8
+
+const (
+	charsetASCII charset = iota
+	charsetUK
+	charsetDECSpecial
+)
+
+// decSpecial maps 0x5f to 0x7e of the DEC special graphics set, used for
+// line drawing, to Unicode.
+var decSpecial = [...]rune{
+	' ', // _ blank
+	'◆', // ` diamond
+	'▒', // a checkerboard
+	'␉', // b HT
+	'␌', // c FF
+	'␍', // d CR
+	'␊', // e LF
+	'°', // f degree
+	'±', // g plus/minus
+	'␤', // h NL
+	'␋', // i VT
+	'┘', // j lower right corner
+	'┐', // k upper right corner
+	'┌', // l upper left corner
+	'└', // m lower left corner
+	'┼', // n crossing lines
+	'⎺', // o scan line 1
+	'⎻', // p scan line 3
+	'─', // q horizontal line
+	'⎼', // r scan line 7
+	'⎽', // s scan line 9
+	'├', // t left tee
+	'┤', // u right tee
+	'┴', // v bottom tee
+	'┬', // w top tee
+	'│', // x vertical line
+	'≤', // y less than or equal
+	'≥', // z greater than or equal
+	'π', // { pi
+	'≠', // | not equal
+	'£', // } pound
+	'·', // ~ centered dot
+}
+
+// designate returns the character set selected by the final byte of a
+// designation sequence such as ESC ( 0.
+func designate(final byte) (charset, bool) {
+	switch final {
+	case 'B':
+		return charsetASCII, true
+	case 'A':
+		return charsetUK, true
+	case '0':
+		return charsetDECSpecial, true
+	}
+	return 0, false
+}
+
+// final returns the final byte of the sequence designating c.
+func (c charset) final() byte {
+	switch c {
+	case charsetUK:
+		return 'A'
+	case charsetDECSpecial:
+		return '0'
+	}
+	return 'B'
+}
+
+func (c charset) translate(r rune) rune {
+	switch c {
+	case charsetUK:
+		if r == '#' {
+			return '£'
+		}
+	case charsetDECSpecial:
+		if r >= 0x5f && r <= 0x7e {
+			return decSpecial[r-0x5f]
+		}
+	}
+	return r
+}
@@ -0,0 +1,94 @@
+package screen
+
+// Color is a terminal color: the default color, an index into the
+// 256-color palette or a 24-bit RGB value. The zero value is the default
+// color.
+type Color uint32
+
+// DefaultColor is the terminal's default foreground or background color.
+const DefaultColor Color = 0
+
+const (
+	colorIndexed = 1 << 24
+	colorRGB     = 2 << 24
+	colorKind    = 0xff << 24
+)
+
+// Indexed returns palette color n. Colors 0 to 7 are the ANSI colors
+// (black, red, green, yellow, blue, magenta, cyan, white), 8 to 15 their
+// bright variants, 16 to 231 a 6x6x6 color cube and 232 to 255 grays.
+func Indexed(n uint8) Color {
+	return Color(colorIndexed | uint32(n))
+}
+
+// RGB returns a 24-bit color.
+func RGB(r, g, b uint8) Color {
+	return Color(colorRGB | uint32(r)<<16 | uint32(g)<<8 | uint32(b))
+}
+
+// Index returns the palette index of c, if it is a palette color.
+func (c Color) Index() (int, bool) {
+	if c&colorKind != colorIndexed {
+		return 0, false
+	}
+	return int(c & 0xff), true
+}
+
+// RGB returns the red, green and blue components of c. Palette colors are
+// converted using the xterm palette. It returns false for DefaultColor.
+func (c Color) RGB() (r, g, b uint8, ok bool) {
+	switch c & colorKind {
+	case colorIndexed:
+		r, g, b = paletteRGB(int(c & 0xff))
+		return r, g, b, true
+	case colorRGB:
+		return uint8(c >> 16), uint8(c >> 8), uint8(c), true
+	}
+	return 0, 0, 0, false
+}
+
+// Nearest16 returns the index of the closest of the 16 basic colors, for
+// outputs which cannot show more. It returns -1 for DefaultColor.
+func (c Color) Nearest16() int {
+	if n, ok := c.Index(); ok && n < 16 {
+		return n
+	}
+	r, g, b, ok := c.RGB()
+	if !ok {
+		return -1
+	}
+	best, dist := 0, -1
+	for i := 0; i < 16; i++ {
+		pr, pg, pb := paletteRGB(i)
+		dr, dg, db := int(r)-int(pr), int(g)-int(pg), int(b)-int(pb)
+		d := dr*dr + dg*dg + db*db
+		if dist < 0 || d < dist {
+			best, dist = i, d
+		}
+	}
+	return best
+}
+
+// basic16 is the xterm default palette for the 16 basic colors.
+var basic16 = [16][3]uint8{
+	{0, 0, 0}, {205, 0, 0}, {0, 205, 0}, {205, 205, 0},
+	{0, 0, 238}, {205, 0, 205}, {0, 205, 205}, {229, 229, 229},
+	{127, 127, 127}, {255, 0, 0}, {0, 255, 0}, {255, 255, 0},
+	{92, 92, 255}, {255, 0, 255}, {0, 255, 255}, {255, 255, 255},
+}
+
+var cubeLevels = [6]uint8{0, 95, 135, 175, 215, 255}
+
+func paletteRGB(n int) (r, g, b uint8) {
+	switch {
+	case n < 16:
+		c := basic16[n]
+		return c[0], c[1], c[2]
+	case n < 232:
+		n -= 16
+		return cubeLevels[n/36], cubeLevels[n/6%6], cubeLevels[n%6]
+	default:
+		v := uint8(8 + (n-232)*10)
+		return v, v, v
+	}
+}
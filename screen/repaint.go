@@ -0,0 +1,183 @@
+package screen
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Repaint returns terminal output which brings a terminal in its initial
+// state to the state of s: the title, both buffers, the saved cursor, the
+// scrolling region, tab stops, character sets, modes, the pen and the
+// cursor. It lets a recording start in the middle of a session.
+func (s *Screen) Repaint() []byte {
+	var b strings.Builder
+	b.WriteString("\x1b[0m\x1b[H\x1b[2J")
+	if s.title != "" {
+		b.WriteString("\x1b]2;" + s.title + "\a")
+	}
+
+	pen := Cell{FG: DefaultColor, BG: DefaultColor}
+	paint(&b, s.primary, &pen)
+
+	// the saved cursor, which also holds the primary cursor while the
+	// alternate buffer is shown
+	cup(&b, s.saved.x, s.saved.y)
+	setPen(&b, s.saved.pen, &pen)
+	if s.alt {
+		b.WriteString("\x1b[?1049h")
+		// the alternate buffer was cleared with the saved pen
+		setPen(&b, Cell{FG: DefaultColor, BG: DefaultColor}, &pen)
+		b.WriteString("\x1b[2J")
+		paint(&b, s.alternate, &pen)
+	} else {
+		b.WriteString("\x1b7")
+	}
+
+	if s.top != 0 || s.bottom != s.height-1 {
+		b.WriteString("\x1b[" + strconv.Itoa(s.top+1) + ";" + strconv.Itoa(s.bottom+1) + "r")
+	}
+	if !defaultTabs(s.tabs) {
+		b.WriteString("\x1b[3g")
+		for x, stop := range s.tabs {
+			if stop {
+				cup(&b, x, 0)
+				b.WriteString("\x1bH")
+			}
+		}
+	}
+	if !s.autowrap {
+		b.WriteString("\x1b[?7l")
+	}
+	if s.origin {
+		b.WriteString("\x1b[?6h")
+	}
+
+	// the cursor, after the region and the modes which move it
+	x, y := s.x, s.y
+	if s.wrapNext && x > 0 && s.lines[y][x].Rune == 0 {
+		x--
+	}
+	if s.origin {
+		y -= s.top
+	}
+	cup(&b, x, y)
+	if s.wrapNext {
+		// the last character is written again to leave the cursor
+		// waiting to wrap
+		c := s.lines[s.y][x]
+		setPen(&b, c, &pen)
+		b.WriteString(c.String())
+	}
+
+	// the character sets, after the characters which do not go through
+	// them
+	for g, cs := range s.charsets {
+		if cs != charsetASCII {
+			b.WriteString("\x1b" + string("()*+"[g]) + string(cs.final()))
+		}
+	}
+	switch s.gl {
+	case 1:
+		b.WriteString("\x0e")
+	case 2:
+		b.WriteString("\x1bn")
+	case 3:
+		b.WriteString("\x1bo")
+	}
+	setPen(&b, s.pen, &pen)
+	if !s.cursorVisible {
+		b.WriteString("\x1b[?25l")
+	}
+	return []byte(b.String())
+}
+
+// paint writes lines from the top left, leaving out the blank cells at the
+// end of each line.
+func paint(b *strings.Builder, lines [][]Cell, pen *Cell) {
+	for y, line := range lines {
+		n := len(line)
+		for n > 0 && isBlank(line[n-1]) {
+			n--
+		}
+		if n == 0 {
+			continue
+		}
+		cup(b, 0, y)
+		for x := 0; x < n; x++ {
+			c := line[x]
+			if c.Rune == 0 {
+				if x > 0 && line[x-1].Wide {
+					continue
+				}
+				c.Rune = ' '
+			}
+			setPen(b, c, pen)
+			b.WriteString(c.String())
+		}
+	}
+}
+
+func isBlank(c Cell) bool {
+	return c == Cell{Rune: ' ', FG: DefaultColor, BG: DefaultColor}
+}
+
+func cup(b *strings.Builder, x, y int) {
+	b.WriteString("\x1b[" + strconv.Itoa(y+1) + ";" + strconv.Itoa(x+1) + "H")
+}
+
+// sgrAttrs are the SGR parameters setting each attribute.
+var sgrAttrs = []struct {
+	attr  Attr
+	param string
+}{
+	{Bold, ";1"}, {Faint, ";2"}, {Italic, ";3"}, {Underline, ";4"},
+	{Blink, ";5"}, {Reverse, ";7"}, {Invisible, ";8"}, {Strikethrough, ";9"},
+}
+
+// setPen writes the SGR and hyperlink changes from pen to the colors,
+// attributes and link of c.
+func setPen(b *strings.Builder, c Cell, pen *Cell) {
+	if c.FG != pen.FG || c.BG != pen.BG || c.Attr != pen.Attr {
+		b.WriteString("\x1b[0")
+		for _, a := range sgrAttrs {
+			if c.Attr&a.attr != 0 {
+				b.WriteString(a.param)
+			}
+		}
+		writeColor(b, c.FG, 30)
+		writeColor(b, c.BG, 40)
+		b.WriteByte('m')
+	}
+	if c.Link != pen.Link {
+		b.WriteString("\x1b]8;;" + c.Link + "\a")
+	}
+	pen.FG, pen.BG, pen.Attr, pen.Link = c.FG, c.BG, c.Attr, c.Link
+}
+
+// writeColor writes the SGR parameters selecting c, with base 30 for the
+// foreground and 40 for the background.
+func writeColor(b *strings.Builder, c Color, base int) {
+	if n, ok := c.Index(); ok {
+		switch {
+		case n < 8:
+			b.WriteString(";" + strconv.Itoa(base+n))
+		case n < 16:
+			b.WriteString(";" + strconv.Itoa(base+60+n-8))
+		default:
+			b.WriteString(";" + strconv.Itoa(base+8) + ";5;" + strconv.Itoa(n))
+		}
+		return
+	}
+	if r, g, bl, ok := c.RGB(); ok {
+		b.WriteString(";" + strconv.Itoa(base+8) + ";2;" + strconv.Itoa(int(r)) + ";" + strconv.Itoa(int(g)) + ";" + strconv.Itoa(int(bl)))
+	}
+}
+
+func defaultTabs(tabs []bool) bool {
+	for x, stop := range tabs {
+		if stop != (x > 0 && x%8 == 0) {
+			return false
+		}
+	}
+	return true
+}
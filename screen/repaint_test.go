@@ -0,0 +1,95 @@
+package screen
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// state is what Repaint has to carry over.
+type state struct {
+	Primary, Alternate [][]Cell
+	Alt                bool
+	X, Y               int
+	Visible            bool
+	Pen                Cell
+	Saved              cursor
+	WrapNext           bool
+	Autowrap, Origin   bool
+	Tabs               []bool
+	Charsets           [4]charset
+	GL                 int
+	Top, Bottom        int
+	Title              string
+}
+
+func stateOf(s *Screen) state {
+	saved := s.saved
+	// the character sets of the saved cursor are not carried over
+	saved.charsets, saved.gl, saved.origin, saved.wrapNext = [4]charset{}, 0, false, false
+	return state{
+		Primary: s.primary, Alternate: s.alternate, Alt: s.alt,
+		X: s.x, Y: s.y, Visible: s.cursorVisible,
+		Pen: s.pen, Saved: saved, WrapNext: s.wrapNext,
+		Autowrap: s.autowrap, Origin: s.origin, Tabs: s.tabs,
+		Charsets: s.charsets, GL: s.gl,
+		Top: s.top, Bottom: s.bottom, Title: s.title,
+	}
+}
+
+func TestRepaint(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+	}{
+		{"empty", ""},
+		{"text", "$ ls\r\nfoo  bar\r\n$ "},
+		{"colors", "\x1b[1;31mred\x1b[0m \x1b[3;4;38;5;200mx\x1b[48;2;1;2;3my\x1b[0m \x1b[7;94;103mz"},
+		{"colored clear", "\x1b[44m\x1b[2J\x1b[0mX"},
+		{"wide", "日本語\x1b[1;79H漢\x1b[3;1Hab"},
+		{"combining", "é é"},
+		{"deferred wrap", strings.Repeat("x", 80)},
+		{"deferred wrap after wide", "\x1b[1;79H漢"},
+		{"alternate", "primary\r\n\x1b[31m\x1b[?1049h\x1b[2;3Halt"},
+		{"saved cursor", "\x1b[5;5H\x1b[4m\x1b7\x1b[0m\x1b[H"},
+		{"origin", "\x1b[3;10r\x1b[?6h\x1b[2;4Hx"},
+		{"region", "\x1b[5;20r\x1b[22;7H"},
+		{"tabs", "\x1b[3g\x1b[5G\x1bH\x1b[30G\x1bH\x1b[H"},
+		{"charsets", "\x1b(0\x1b)A\x1b+0\x0eq#\x0fq"},
+		{"title and link", "\x1b]2;my title\a\x1b]8;;http://example.com/\atext\x1b]8;;\a \x1b]8;;http://x/\a"},
+		{"modes", "\x1b[?25l\x1b[?7lnowrap"},
+		{"deferred wrap restored without autowrap", strings.Repeat("x", 80) + "\x1b7\x1b[?7l\x1b8"},
+	}
+	for _, tt := range tests {
+		s := New(80, 24)
+		s.Write([]byte(tt.in))
+		r := New(80, 24)
+		r.Write(s.Repaint())
+
+		want, got := stateOf(s), stateOf(r)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: repainted screen differs\n%q\ngot  %+v\nwant %+v", tt.name, s.Repaint(),
+				summary(got), summary(want))
+		}
+	}
+}
+
+// summary leaves the cells out of a state for error messages, except the
+// text.
+func summary(st state) state {
+	text := func(lines [][]Cell) [][]Cell {
+		var out [][]Cell
+		for _, l := range lines {
+			for _, c := range l {
+				if c != (Cell{Rune: ' '}) {
+					out = append(out, l)
+					break
+				}
+			}
+		}
+		return out
+	}
+	st.Primary, st.Alternate = text(st.Primary), text(st.Alternate)
+	st.Tabs = nil
+	return st
+}
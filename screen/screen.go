@@ -0,0 +1,492 @@
+// Package screen implements a virtual terminal screen. A Screen consumes
+// the byte stream written to a terminal and keeps the resulting grid of
+// cells, the cursor and the terminal modes, so that players and exporters
+// can render a recording without a real terminal.
+package screen
+
+import (
+	"strings"
+
+	"github.com/mattn/go-runewidth"
+	"github.com/mattn/ttyrec4windows/ansi"
+)
+
+// Attr is a set of character attributes.
+type Attr uint8
+
+// Character attributes.
+const (
+	Bold Attr = 1 << iota
+	Faint
+	Italic
+	Underline
+	Blink
+	Reverse
+	Invisible
+	Strikethrough
+)
+
+// Cell is a single character position on the screen. A wide character
+// takes two cells: the first holds the rune and has Wide set, the second
+// is a continuation cell with a zero Rune.
+type Cell struct {
+	Rune rune
+	Comb string // combining marks drawn over Rune
+	Wide bool
+	Link string // target of the OSC 8 hyperlink the cell belongs to
+	FG   Color
+	BG   Color
+	Attr Attr
+}
+
+// String returns the character of the cell with its combining marks, or
+// the empty string for the continuation of a wide character.
+func (c Cell) String() string {
+	if c.Rune == 0 {
+		return ""
+	}
+	return string(c.Rune) + c.Comb
+}
+
+// cursor is the state saved and restored with the cursor.
+type cursor struct {
+	x, y     int
+	pen      Cell
+	charsets [4]charset
+	gl       int
+	origin   bool
+	wrapNext bool
+}
+
+// Screen is a virtual terminal screen.
+type Screen struct {
+	width  int
+	height int
+	lines  [][]Cell // the active buffer, primary or alternate
+
+	primary   [][]Cell
+	alternate [][]Cell
+	alt       bool
+
+	x, y          int
+	cursorVisible bool
+	pen           Cell
+	saved         cursor
+
+	// wrapNext is set when a character was written to the last column:
+	// the cursor stays there and wraps before the next character.
+	wrapNext bool
+	autowrap bool
+	origin   bool
+	tabs     []bool
+
+	last rune // the last graphic character, repeated by REP
+
+	// character sets designated into G0 to G3, the one invoked into GL
+	// and the one selected by a single shift for the next character
+	charsets [4]charset
+	gl       int
+	single   int
+
+	// scrolling region, inclusive
+	top, bottom int
+
+	title string
+
+	p *ansi.Parser
+}
+
+// New returns a blank screen of the given size.
+func New(width, height int) *Screen {
+	s := &Screen{}
+	s.p = ansi.NewParser((*handler)(s))
+	s.Resize(width, height)
+	s.reset()
+	return s
+}
+
+// Reset clears the screen and restores the initial state, dropping any
+// partial escape sequence.
+func (s *Screen) Reset() {
+	s.p.Reset()
+	s.reset()
+}
+
+func (s *Screen) reset() {
+	s.pen = Cell{Rune: ' ', FG: DefaultColor, BG: DefaultColor}
+	s.x, s.y = 0, 0
+	s.cursorVisible = true
+	s.wrapNext = false
+	s.last = 0
+	s.autowrap = true
+	s.origin = false
+	for x := range s.tabs {
+		s.tabs[x] = x > 0 && x%8 == 0
+	}
+	s.top, s.bottom = 0, s.height-1
+	s.charsets = [4]charset{}
+	s.gl, s.single = 0, 0
+	s.saved = cursor{pen: s.pen}
+	s.alt = false
+	s.lines = s.alternate
+	s.clearAll()
+	s.lines = s.primary
+	s.clearAll()
+}
+
+// Resize changes the size of the screen, keeping the top left content.
+func (s *Screen) Resize(width, height int) {
+	if width < 1 {
+		width = 1
+	}
+	if height < 1 {
+		height = 1
+	}
+	s.primary = s.resizeLines(s.primary, width, height)
+	s.alternate = s.resizeLines(s.alternate, width, height)
+	s.lines = s.primary
+	if s.alt {
+		s.lines = s.alternate
+	}
+	for x := len(s.tabs); x < width; x++ {
+		s.tabs = append(s.tabs, x > 0 && x%8 == 0)
+	}
+	s.tabs = s.tabs[:width]
+	s.width, s.height = width, height
+	s.top, s.bottom = 0, height-1
+	s.moveTo(s.x, s.y)
+}
+
+func (s *Screen) resizeLines(old [][]Cell, width, height int) [][]Cell {
+	lines := make([][]Cell, height)
+	for y := range lines {
+		lines[y] = make([]Cell, width)
+		for x := range lines[y] {
+			lines[y][x] = s.blank()
+		}
+		if y < len(old) {
+			copy(lines[y], old[y])
+		}
+		// a wide character cut in half by a narrower screen is erased
+		if last := &lines[y][width-1]; last.Wide {
+			*last = s.blank()
+		}
+	}
+	return lines
+}
+
+// Size returns the width and height of the screen.
+func (s *Screen) Size() (width, height int) {
+	return s.width, s.height
+}
+
+// Cell returns the cell at x, y.
+func (s *Screen) Cell(x, y int) Cell {
+	return s.lines[y][x]
+}
+
+// Line returns a copy of line y.
+func (s *Screen) Line(y int) []Cell {
+	return append([]Cell(nil), s.lines[y]...)
+}
+
+// AltScreen reports whether the alternate screen buffer, used by full
+// screen programs, is shown.
+func (s *Screen) AltScreen() bool {
+	return s.alt
+}
+
+// Title returns the window title last set by the program.
+func (s *Screen) Title() string {
+	return s.title
+}
+
+// Cursor returns the cursor position and whether it is visible.
+func (s *Screen) Cursor() (x, y int, visible bool) {
+	return s.x, s.y, s.cursorVisible
+}
+
+// String returns the text of the screen, one line per row with trailing
+// spaces removed.
+func (s *Screen) String() string {
+	var b strings.Builder
+	for y := range s.lines {
+		b.WriteString(s.Text(y))
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// Text returns the text of line y with trailing spaces removed.
+func (s *Screen) Text(y int) string {
+	var b strings.Builder
+	for _, c := range s.lines[y] {
+		b.WriteString(c.String())
+	}
+	return strings.TrimRight(b.String(), " ")
+}
+
+// Write feeds terminal output to the screen. Escape sequences may be split
+// across calls.
+func (s *Screen) Write(b []byte) (int, error) {
+	return s.p.Write(b)
+}
+
+func (s *Screen) blank() Cell {
+	return Cell{Rune: ' ', FG: DefaultColor, BG: s.pen.BG}
+}
+
+func (s *Screen) clampX(x int) int {
+	if x < 0 {
+		return 0
+	}
+	if x >= s.width {
+		return s.width - 1
+	}
+	return x
+}
+
+func (s *Screen) clampY(y int) int {
+	if y < 0 {
+		return 0
+	}
+	if y >= s.height {
+		return s.height - 1
+	}
+	return y
+}
+
+func (s *Screen) moveTo(x, y int) {
+	s.x, s.y = s.clampX(x), s.clampY(y)
+	s.wrapNext = false
+}
+
+// cursorTo moves the cursor to x, y counted from the home position, which
+// is the top of the scrolling region in origin mode.
+func (s *Screen) cursorTo(x, y int) {
+	if !s.origin {
+		s.moveTo(x, y)
+		return
+	}
+	y += s.top
+	if y > s.bottom {
+		y = s.bottom
+	}
+	s.moveTo(x, y)
+}
+
+// clearLine blanks the cells from x0 up to x1 of line y.
+func (s *Screen) clearLine(y, x0, x1 int) {
+	line := s.lines[y]
+	// do not leave half of a wide character at either end
+	if x0 > 0 && x0 < len(line) && line[x0].Rune == 0 {
+		line[x0-1] = s.blank()
+	}
+	if x1 < len(line) && line[x1].Rune == 0 {
+		line[x1] = s.blank()
+	}
+	for x := x0; x < x1 && x < len(line); x++ {
+		line[x] = s.blank()
+	}
+}
+
+// split blanks the other half of a wide character at x of line, so that
+// the cell can be overwritten without leaving half a character behind.
+func (s *Screen) split(line []Cell, x int) {
+	switch {
+	case line[x].Rune == 0 && x > 0:
+		line[x-1] = s.blank()
+		line[x] = s.blank()
+	case line[x].Wide && x+1 < len(line):
+		line[x+1] = s.blank()
+		line[x].Wide = false
+	}
+}
+
+func (s *Screen) clearAll() {
+	for y := range s.lines {
+		s.clearLine(y, 0, s.width)
+	}
+}
+
+func (s *Screen) saveCursor() {
+	s.saved = cursor{
+		x:        s.x,
+		y:        s.y,
+		pen:      s.pen,
+		charsets: s.charsets,
+		gl:       s.gl,
+		origin:   s.origin,
+		wrapNext: s.wrapNext,
+	}
+}
+
+func (s *Screen) restoreCursor() {
+	s.moveTo(s.saved.x, s.saved.y)
+	s.pen = s.saved.pen
+	s.charsets, s.gl = s.saved.charsets, s.saved.gl
+	s.origin = s.saved.origin
+	// like xterm, a pending wrap saved before DECAWM was reset is
+	// dropped, as the next character could not wrap
+	s.wrapNext = s.saved.wrapNext && s.autowrap
+}
+
+// switchBuffer shows the alternate or the primary buffer.
+func (s *Screen) switchBuffer(alt bool) {
+	s.alt = alt
+	if alt {
+		s.lines = s.alternate
+	} else {
+		s.lines = s.primary
+	}
+}
+
+// scrollUp moves the lines of the scrolling region up by n, blanking the
+// lines at the bottom.
+func (s *Screen) scrollUp(n int) {
+	s.deleteLinesAt(s.top, n)
+}
+
+// scrollDown moves the lines of the scrolling region down by n, blanking
+// the lines at the top.
+func (s *Screen) scrollDown(n int) {
+	s.insertLinesAt(s.top, n)
+}
+
+// deleteLinesAt removes n lines at y, moving the lines below it up to the
+// bottom of the scrolling region.
+func (s *Screen) deleteLinesAt(y, n int) {
+	if n > s.bottom-y+1 {
+		n = s.bottom - y + 1
+	}
+	for i := 0; i < n; i++ {
+		line := s.lines[y]
+		copy(s.lines[y:s.bottom+1], s.lines[y+1:s.bottom+1])
+		s.lines[s.bottom] = line
+		s.clearLine(s.bottom, 0, s.width)
+	}
+}
+
+// insertLinesAt inserts n blank lines at y, moving the lines below it
+// down and dropping those pushed past the bottom of the scrolling region.
+func (s *Screen) insertLinesAt(y, n int) {
+	if n > s.bottom-y+1 {
+		n = s.bottom - y + 1
+	}
+	for i := 0; i < n; i++ {
+		line := s.lines[s.bottom]
+		copy(s.lines[y+1:s.bottom+1], s.lines[y:s.bottom])
+		s.lines[y] = line
+		s.clearLine(y, 0, s.width)
+	}
+}
+
+// inRegion reports whether the cursor is inside the scrolling region.
+func (s *Screen) inRegion() bool {
+	return s.top <= s.y && s.y <= s.bottom
+}
+
+// translate maps r through the character set invoked for it.
+func (s *Screen) translate(r rune) rune {
+	g := s.gl
+	if s.single != 0 {
+		g, s.single = s.single, 0
+	}
+	return s.charsets[g].translate(r)
+}
+
+func (s *Screen) print(r rune) {
+	s.last = r
+	r = s.translate(r)
+	w := runewidth.RuneWidth(r)
+	if w == 0 {
+		s.combine(r)
+		return
+	}
+	if w > s.width {
+		w = 1
+	}
+	if s.wrapNext {
+		s.x = 0
+		s.lineFeed()
+	}
+	if s.x+w > s.width {
+		// a wide character does not fit in the last column
+		if s.autowrap {
+			s.x = 0
+			s.lineFeed()
+		} else {
+			s.x = s.width - w
+		}
+	}
+	line := s.lines[s.y]
+	s.split(line, s.x)
+	c := s.pen
+	c.Rune = r
+	if w == 2 {
+		s.split(line, s.x+1)
+		c.Wide = true
+		line[s.x+1] = s.pen
+		line[s.x+1].Rune = 0
+	}
+	line[s.x] = c
+	s.x += w
+	if s.x >= s.width {
+		s.x = s.width - 1
+		s.wrapNext = s.autowrap
+	}
+}
+
+// combine attaches a zero width character, such as a combining accent,
+// to the character before the cursor.
+func (s *Screen) combine(r rune) {
+	x := s.x - 1
+	if s.wrapNext {
+		// the cursor is still on the character
+		x = s.x
+	}
+	if x < 0 {
+		return
+	}
+	line := s.lines[s.y]
+	if line[x].Rune == 0 && x > 0 {
+		x--
+	}
+	line[x].Comb += string(r)
+}
+
+func (s *Screen) lineFeed() {
+	s.wrapNext = false
+	if s.y == s.bottom {
+		s.scrollUp(1)
+	} else if s.y < s.height-1 {
+		s.y++
+	}
+}
+
+func (s *Screen) reverseIndex() {
+	s.wrapNext = false
+	if s.y == s.top {
+		s.scrollDown(1)
+	} else if s.y > 0 {
+		s.y--
+	}
+}
+
+// tab moves the cursor to the next tab stop, or the last column.
+func (s *Screen) tab() {
+	x := s.x + 1
+	for x < s.width-1 && !s.tabs[x] {
+		x++
+	}
+	s.moveTo(x, s.y)
+}
+
+// backTab moves the cursor to the previous tab stop, or the first column.
+func (s *Screen) backTab() {
+	x := s.x - 1
+	for x > 0 && !s.tabs[x] {
+		x--
+	}
+	s.moveTo(x, s.y)
+}
@@ -0,0 +1,412 @@
+package screen
+
+import (
+	"strings"
+	"testing"
+)
+
+// screenTest feeds in to a screen and compares the text and the cursor.
+type screenTest struct {
+	name string
+	in   string
+	want []string // the lines of the screen from the top, the rest blank
+	x, y int
+}
+
+func runTests(t *testing.T, width, height int, tests []screenTest) {
+	t.Helper()
+	for _, tt := range tests {
+		s := New(width, height)
+		s.Write([]byte(tt.in))
+		want := strings.Join(tt.want, "\n") + strings.Repeat("\n", height-len(tt.want))
+		if len(tt.want) > 0 {
+			want += "\n"
+		}
+		if got := s.String(); got != want {
+			t.Errorf("%s: got screen\n%s\nwant\n%s", tt.name, got, want)
+		}
+		if x, y, _ := s.Cursor(); x != tt.x || y != tt.y {
+			t.Errorf("%s: cursor at %d,%d, want %d,%d", tt.name, x, y, tt.x, tt.y)
+		}
+	}
+}
+
+func TestScrollRegion(t *testing.T) {
+	runTests(t, 10, 5, []screenTest{
+		{
+			name: "LF at the bottom margin",
+			in:   "1\r\n2\r\n3\r\n4\r\n5\x1b[2;4r\x1b[4;1Hx\ny",
+			want: []string{"1", "3", "x", " y", "5"},
+			x:    2, y: 3,
+		},
+		{
+			name: "LF below the region",
+			in:   "1\r\n2\r\n3\r\n4\r\n5\x1b[2;3r\x1b[5;1H\n\n",
+			want: []string{"1", "2", "3", "4", "5"},
+			x:    0, y: 4,
+		},
+		{
+			name: "RI at the top margin",
+			in:   "1\r\n2\r\n3\r\n4\r\n5\x1b[2;4r\x1b[2;1H\x1bM",
+			want: []string{"1", "", "2", "3", "5"},
+			x:    0, y: 1,
+		},
+		{
+			name: "IL",
+			in:   "1\r\n2\r\n3\r\n4\r\n5\x1b[1;4r\x1b[2;3H\x1b[L",
+			want: []string{"1", "", "2", "3", "5"},
+			x:    0, y: 1,
+		},
+		{
+			name: "IL outside the region",
+			in:   "1\r\n2\r\n3\r\n4\r\n5\x1b[1;3r\x1b[5;1H\x1b[L",
+			want: []string{"1", "2", "3", "4", "5"},
+			x:    0, y: 4,
+		},
+		{
+			name: "DL",
+			in:   "1\r\n2\r\n3\r\n4\r\n5\x1b[1;4r\x1b[2;1H\x1b[2M",
+			want: []string{"1", "4", "", "", "5"},
+			x:    0, y: 1,
+		},
+		{
+			name: "SU and SD",
+			in:   "1\r\n2\r\n3\r\n4\r\n5\x1b[2;4r\x1b[S\x1b[2T",
+			want: []string{"1", "", "", "3", "5"},
+			x:    0, y: 0,
+		},
+		{
+			name: "CUU stops at the top margin",
+			in:   "\x1b[2;4r\x1b[3;1H\x1b[10A",
+			x:    0, y: 1,
+		},
+		{
+			name: "CUD stops at the bottom margin",
+			in:   "\x1b[2;4r\x1b[3;1H\x1b[10B",
+			x:    0, y: 3,
+		},
+		{
+			name: "CUU above the region",
+			in:   "\x1b[3;4r\x1b[2;1H\x1b[10A",
+			x:    0, y: 0,
+		},
+		{
+			name: "CUD below the region",
+			in:   "\x1b[2;3r\x1b[4;1H\x1b[10B",
+			x:    0, y: 4,
+		},
+	})
+}
+
+func TestText(t *testing.T) {
+	runTests(t, 10, 4, []screenTest{
+		{
+			name: "CR LF BS",
+			in:   "abc\r\ndef\bX",
+			want: []string{"abc", "deX"},
+			x:    3, y: 1,
+		},
+		{
+			name: "scroll at the bottom",
+			in:   "1\r\n2\r\n3\r\n4\r\n5",
+			want: []string{"2", "3", "4", "5"},
+			x:    1, y: 3,
+		},
+		{
+			name: "ED 0",
+			in:   "aaaa\r\nbbbb\r\ncccc\x1b[2;3H\x1b[J",
+			want: []string{"aaaa", "bb"},
+			x:    2, y: 1,
+		},
+		{
+			name: "ED 1",
+			in:   "aaaa\r\nbbbb\r\ncccc\x1b[2;3H\x1b[1J",
+			want: []string{"", "   b", "cccc"},
+			x:    2, y: 1,
+		},
+		{
+			name: "EL",
+			in:   "abcdef\x1b[1;3H\x1b[K\r\nabcdef\x1b[2;3H\x1b[1K\r\nabcdef\x1b[2K",
+			want: []string{"ab", "   def"},
+			x:    6, y: 2,
+		},
+		{
+			name: "ICH",
+			in:   "abcdef\x1b[1;2H\x1b[2@",
+			want: []string{"a  bcdef"},
+			x:    1, y: 0,
+		},
+		{
+			name: "ICH pushes off the end",
+			in:   "0123456789\x1b[1;1H\x1b[3@",
+			want: []string{"   0123456"},
+			x:    0, y: 0,
+		},
+	})
+}
+
+func TestSplitWrites(t *testing.T) {
+	in := "a\x1b[1;31mb\x1b]2;title\a日本\x1b[2;3Hc\x1b(0q\x1b[38:2::1:2:3md"
+	whole := New(10, 3)
+	whole.Write([]byte(in))
+	split := New(10, 3)
+	for i := 0; i < len(in); i++ {
+		split.Write([]byte{in[i]})
+	}
+	if got, want := split.String(), whole.String(); got != want {
+		t.Errorf("written a byte at a time:\n%s\nat once:\n%s", got, want)
+	}
+	for y := 0; y < 3; y++ {
+		for x := 0; x < 10; x++ {
+			if a, b := split.Cell(x, y), whole.Cell(x, y); a != b {
+				t.Errorf("cell %d,%d: %+v, want %+v", x, y, a, b)
+			}
+		}
+	}
+	if split.Title() != "title" {
+		t.Errorf("title %q", split.Title())
+	}
+}
+
+func TestCursorMovement(t *testing.T) {
+	runTests(t, 10, 4, []screenTest{
+		{"CUP", "\x1b[3;5Hx", []string{"", "", "    x"}, 5, 2},
+		{"CUP clamped", "\x1b[99;99H", nil, 9, 3},
+		{"HVP", "\x1b[2;3fx", []string{"", "  x"}, 3, 1},
+		{"CUF and CUB", "\x1b[5Cx\x1b[3Dy", []string{"   y x"}, 4, 0},
+		{"CNL and CPL", "ab\x1b[2Ex\x1b[Fy", []string{"ab", "y", "x"}, 1, 1},
+		{"CHA", "abcdef\x1b[3Gx", []string{"abxdef"}, 3, 0},
+		{"HPA", "abcdef\x1b[2`x", []string{"axcdef"}, 2, 0},
+		{"VPA", "ab\x1b[3dx", []string{"ab", "", "  x"}, 3, 2},
+		{"VPA keeps the column", "\x1b[4G\x1b[2dx", []string{"", "   x"}, 4, 1},
+	})
+}
+
+func TestEditing(t *testing.T) {
+	runTests(t, 10, 2, []screenTest{
+		{"DCH", "abcdef\x1b[2G\x1b[2P", []string{"adef"}, 1, 0},
+		{"DCH past the end", "abcdef\x1b[4G\x1b[99P", []string{"abc"}, 3, 0},
+		{"DCH splits a wide character", "a日本\x1b[3G\x1b[P", []string{"a 本"}, 2, 0},
+		{"ECH", "abcdef\x1b[2G\x1b[3X", []string{"a   ef"}, 1, 0},
+		{"ECH keeps the cursor", "abcdef\x1b[2G\x1b[3Xx", []string{"ax  ef"}, 2, 0},
+		{"REP", "ab\x1b[3b", []string{"abbbb"}, 5, 0},
+		{"REP wraps", "ab\x1b[12b", []string{"abbbbbbbbb", "bbbb"}, 4, 1},
+		{"REP without a character", "\x1b[3bx", []string{"x"}, 1, 0},
+	})
+}
+
+func TestWide(t *testing.T) {
+	runTests(t, 6, 3, []screenTest{
+		{"wide characters", "日本語", []string{"日本語"}, 5, 0},
+		{"wide at the last column wraps", "abcde日", []string{"abcde", "日"}, 2, 1},
+		{"wide at the last column without autowrap", "\x1b[?7labcde日", []string{"abcd日"}, 5, 0},
+		{"overwrite the first half", "日本\x1b[1;1Hx", []string{"x 本"}, 1, 0},
+		{"overwrite the second half", "日本\x1b[1;2Hx", []string{" x本"}, 2, 0},
+		{"wide over a wide half", "日本\x1b[1;2H語", []string{" 語"}, 3, 0},
+		{"combining marks", "éạ̀", []string{"éạ̀"}, 2, 0},
+		{"combining on a wide character", "日゙", []string{"日゙"}, 2, 0},
+		{"combining at the last column", "abcdef́", []string{"abcdef́"}, 5, 0},
+		{"erase half of a wide character", "日本\x1b[1;2H\x1b[K", []string{""}, 1, 0},
+	})
+
+	s := New(6, 1)
+	s.Write([]byte("a日"))
+	if c := s.Cell(1, 0); c.Rune != '日' || !c.Wide {
+		t.Errorf("lead cell %+v", c)
+	}
+	if c := s.Cell(2, 0); c.Rune != 0 || c.String() != "" {
+		t.Errorf("continuation cell %+v", c)
+	}
+}
+
+func TestDeferredWrap(t *testing.T) {
+	runTests(t, 5, 3, []screenTest{
+		{"cursor stays on the last column", "abcde", []string{"abcde"}, 4, 0},
+		{"wraps before the next character", "abcdef", []string{"abcde", "f"}, 1, 1},
+		{"CR cancels the wrap", "abcde\rx", []string{"xbcde"}, 1, 0},
+		{"cursor movement cancels the wrap", "abcde\x1b[Dx", []string{"abcxe"}, 4, 0},
+		{"no wrap without DECAWM", "\x1b[?7labcdefg", []string{"abcdg"}, 4, 0},
+		{"LF after the last column", "abcde\r\nx", []string{"abcde", "x"}, 1, 1},
+		{"DECRC without DECAWM drops the wrap", "abcde\x1b7\x1b[?7l\x1b8f", []string{"abcdf"}, 4, 0},
+		{"DECRC keeps the wrap", "abcde\x1b7\x1b[H\x1b8f", []string{"abcde", "f"}, 1, 1},
+	})
+}
+
+func TestSaveCursor(t *testing.T) {
+	runTests(t, 10, 4, []screenTest{
+		{"DECSC and DECRC", "\x1b[2;3H\x1b7\x1b[4;1Hx\x1b8y", []string{"", "  y", "", "x"}, 3, 1},
+		{"SCOSC and SCORC", "\x1b[2;3H\x1b[s\x1b[Hx\x1b[uy", []string{"x", "  y"}, 3, 1},
+		{"restore without save", "ab\x1b8x", []string{"xb"}, 1, 0},
+	})
+
+	s := New(10, 2)
+	s.Write([]byte("\x1b[31m\x1b(0\x1b[1;2H\x1b7\x1b[0m\x1b(B\x1b[1;1Hq\x1b8q"))
+	if c := s.Cell(0, 0); c.Rune != 'q' || c.FG != DefaultColor {
+		t.Errorf("before restore: %+v", c)
+	}
+	if c := s.Cell(1, 0); c.Rune != '─' || c.FG != Indexed(1) {
+		t.Errorf("after restore: %+v", c)
+	}
+}
+
+func TestOriginMode(t *testing.T) {
+	runTests(t, 10, 5, []screenTest{
+		{"DECOM homes to the region", "\x1b[2;4r\x1b[?6hx", []string{"", "x"}, 1, 1},
+		{"CUP is relative", "\x1b[2;4r\x1b[?6h\x1b[2;3Hx", []string{"", "", "  x"}, 3, 2},
+		{"CUP is clamped to the region", "\x1b[2;4r\x1b[?6h\x1b[9;1Hx", []string{"", "", "", "x"}, 1, 3},
+		{"VPA is relative", "\x1b[2;4r\x1b[?6h\x1b[3dx", []string{"", "", "", "x"}, 1, 3},
+		{"reset homes to the screen", "\x1b[2;4r\x1b[?6h\x1b[?6lx", []string{"x"}, 1, 0},
+	})
+}
+
+func TestTabs(t *testing.T) {
+	runTests(t, 20, 2, []screenTest{
+		{"default stops", "a\tb\tc", []string{"a       b       c"}, 17, 0},
+		{"last column", "\t\t\t\tx", []string{"                   x"}, 19, 0},
+		{"HTS", "\x1b[1;4H\x1bH\r\tx", []string{"   x"}, 4, 0},
+		{"TBC 0", "\x1b[1;9H\x1b[g\r\tx", []string{"                x"}, 17, 0},
+		{"TBC 3", "\x1b[3g\tx", []string{"                   x"}, 19, 0},
+		{"CHT", "\x1b[2Ix", []string{"                x"}, 17, 0},
+		{"CBT", "\x1b[1;19H\x1b[2Zx", []string{"        x"}, 9, 0},
+	})
+}
+
+func TestCharsets(t *testing.T) {
+	runTests(t, 20, 2, []screenTest{
+		{"DEC graphics in G0", "\x1b(0lqk\x1b(Bq", []string{"┌─┐q"}, 4, 0},
+		{"SO and SI", "\x1b)0q\x0eq\x0fq", []string{"q─q"}, 3, 0},
+		{"UK", "\x1b(A#\x1b(B#", []string{"£#"}, 2, 0},
+		{"SS2", "\x1b*0\x1bNqq", []string{"─q"}, 2, 0},
+		{"SS3", "\x1b+0\x1bOqq", []string{"─q"}, 2, 0},
+		{"LS2", "\x1b*0\x1bnq\x0fq", []string{"─q"}, 2, 0},
+		{"reset", "\x1b(0\x1bcq", []string{"q"}, 1, 0},
+	})
+}
+
+func TestAltScreen(t *testing.T) {
+	runTests(t, 10, 3, []screenTest{
+		{"1049 saves and clears", "shell\x1b[?1049hvim", []string{"     vim"}, 8, 0},
+		{"1049 restores", "shell\x1b[?1049h\x1b[3;3Hvim\x1b[?1049l!", []string{"shell!"}, 6, 0},
+		{"1049 starts blank", "a\x1b[?1049hb\x1b[?1049l\x1b[?1049h", nil, 1, 0},
+		{"47 keeps the cursor", "ab\x1b[?47hc\x1b[?47ld", []string{"ab d"}, 4, 0},
+		{"1047 clears on exit", "a\x1b[?1047hb\x1b[?1047l\x1b[?1047h", nil, 2, 0},
+	})
+}
+
+func TestColors(t *testing.T) {
+	s := New(10, 1)
+	s.Write([]byte("\x1b[1;31;42ma\x1b[0;38;5;200;48;2;1;2;3mb\x1b[38:2::4:5:6;7mc\x1b[22;39;49;27;94md\x1b[me"))
+	tests := []struct {
+		fg, bg Color
+		attr   Attr
+	}{
+		{Indexed(1), Indexed(2), Bold},
+		{Indexed(200), RGB(1, 2, 3), 0},
+		{RGB(4, 5, 6), RGB(1, 2, 3), Reverse},
+		{Indexed(12), DefaultColor, 0},
+		{DefaultColor, DefaultColor, 0},
+	}
+	for x, tt := range tests {
+		c := s.Cell(x, 0)
+		if c.FG != tt.fg || c.BG != tt.bg || c.Attr != tt.attr {
+			t.Errorf("cell %d %q: fg %x bg %x attr %b, want fg %x bg %x attr %b",
+				x, c.Rune, c.FG, c.BG, c.Attr, tt.fg, tt.bg, tt.attr)
+		}
+	}
+}
+
+func TestOSC(t *testing.T) {
+	s := New(10, 1)
+	s.Write([]byte("\x1b]0;first\a\x1b]2;second\x1b\\a\x1b]8;id=1;http://example.com/\abc\x1b]8;;\ad"))
+	if title := s.Title(); title != "second" {
+		t.Errorf("title %q, want %q", title, "second")
+	}
+	for x, want := range []string{"", "http://example.com/", "http://example.com/", ""} {
+		if c := s.Cell(x, 0); c.Link != want {
+			t.Errorf("cell %d %q: link %q, want %q", x, c.Rune, c.Link, want)
+		}
+	}
+	if text := s.Text(0); text != "abcd" {
+		t.Errorf("text %q, want %q", text, "abcd")
+	}
+}
+
+func TestResize(t *testing.T) {
+	s := New(10, 3)
+	s.Write([]byte("abcdef\r\n12\r\nxyz"))
+	s.Resize(4, 2)
+	if got, want := s.String(), "abcd\n12\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if x, y, _ := s.Cursor(); x != 3 || y != 1 {
+		t.Errorf("cursor at %d,%d, want 3,1", x, y)
+	}
+
+	s = New(10, 1)
+	s.Write([]byte("abc日本"))
+	s.Resize(6, 1)
+	if got, want := s.String(), "abc日\n"; got != want {
+		t.Errorf("wide character on the last column: got %q, want %q", got, want)
+	}
+	if c := s.Cell(5, 0); c.Wide || c.Rune != ' ' {
+		t.Errorf("last column %+v", c)
+	}
+}
+
+func TestRecorderOutput(t *testing.T) {
+	// frames as ttyrec writes them: changed lines in full with the console
+	// attributes as SGR, then the cursor and its visibility
+	tests := []struct {
+		name    string
+		frames  []string
+		want    string
+		x, y    int
+		visible bool
+	}{
+		{
+			name: "cursor hidden and shown",
+			frames: []string{
+				"\x1b[1;1H\x1b[37;40mC:\\>\x1b[0m\x1b[2;1H\x1b[37;40m    \x1b[0m\x1b[1;5H",
+				"\x1b[?25l",
+				"\x1b[1;1H\x1b[37;40mC:\\>dir\x1b[0m\x1b[1;8H\x1b[?25h",
+			},
+			want: "C:\\>dir", x: 7, y: 0, visible: true,
+		},
+		{
+			name: "cursor hidden",
+			frames: []string{
+				"\x1b[1;1H\x1b[37;40mC:\\>\x1b[0m\x1b[1;5H",
+				"\x1b[2;1H\x1b[37;40mprogress\x1b[0m\x1b[2;9H\x1b[?25l",
+			},
+			want: "C:\\>\nprogress", x: 8, y: 1, visible: false,
+		},
+		{
+			name: "cursor hidden by earlier versions",
+			frames: []string{
+				"\x1b[1;1H\x1b[37;40mC:\\>\x1b[0m\x1b[1;5H",
+				"\x1b[>5h",
+			},
+			want: "C:\\>", x: 4, y: 0, visible: false,
+		},
+		{
+			name: "cursor shown by earlier versions",
+			frames: []string{
+				"\x1b[>5h\x1b[1;1H\x1b[37;40mC:\\>\x1b[0m\x1b[1;5H",
+				"\x1b[>5l",
+			},
+			want: "C:\\>", x: 4, y: 0, visible: true,
+		},
+	}
+	for _, tt := range tests {
+		s := New(10, 3)
+		for _, f := range tt.frames {
+			s.Write([]byte(f))
+		}
+		if got := strings.TrimRight(s.String(), "\n"); got != tt.want {
+			t.Errorf("%s: got screen\n%s\nwant\n%s", tt.name, got, tt.want)
+		}
+		if x, y, visible := s.Cursor(); x != tt.x || y != tt.y || visible != tt.visible {
+			t.Errorf("%s: cursor at %d,%d visible %v, want %d,%d visible %v", tt.name, x, y, visible, tt.x, tt.y, tt.visible)
+		}
+	}
+}
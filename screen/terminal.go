@@ -0,0 +1,440 @@
+package screen
+
+import (
+	"strings"
+
+	"github.com/mattn/ttyrec4windows/ansi"
+)
+
+// handler receives the events of the parser for a Screen.
+type handler Screen
+
+func (h *handler) Print(r rune)    { (*Screen)(h).print(r) }
+func (h *handler) Execute(r rune)  { (*Screen)(h).execute(r) }
+func (h *handler) ESC(e *ansi.ESC) { (*Screen)(h).esc(e) }
+func (h *handler) CSI(c *ansi.CSI) { (*Screen)(h).csi(c) }
+func (h *handler) OSC(o *ansi.OSC) { (*Screen)(h).osc(string(o.Data)) }
+func (h *handler) DCS(d *ansi.DCS) {}
+
+func (s *Screen) execute(r rune) {
+	switch r {
+	case '\b':
+		s.moveTo(s.x-1, s.y)
+	case '\t':
+		s.tab()
+	case '\n', '\v', '\f':
+		s.lineFeed()
+	case '\r':
+		s.moveTo(0, s.y)
+	case 0x0e: // SO
+		s.gl = 1
+	case 0x0f: // SI
+		s.gl = 0
+	case 0x84: // IND
+		s.lineFeed()
+	case 0x85: // NEL
+		s.x = 0
+		s.lineFeed()
+	case 0x88: // HTS
+		s.tabs[s.x] = true
+	case 0x8d: // RI
+		s.reverseIndex()
+	case 0x8e: // SS2
+		s.single = 2
+	case 0x8f: // SS3
+		s.single = 3
+	}
+}
+
+// osc handles an operating system command. Commands other than the
+// window title and hyperlinks are ignored.
+func (s *Screen) osc(data string) {
+	i := strings.IndexByte(data, ';')
+	if i < 0 {
+		return
+	}
+	cmd, arg := data[:i], data[i+1:]
+	switch cmd {
+	case "0", "1", "2":
+		s.title = arg
+	case "8":
+		// OSC 8 ; params ; URI starts a link, an empty URI ends it
+		if i := strings.IndexByte(arg, ';'); i >= 0 {
+			s.pen.Link = arg[i+1:]
+		}
+	}
+}
+
+func (s *Screen) esc(e *ansi.ESC) {
+	if len(e.Intermediates) > 0 {
+		s.escIntermediate(e)
+		return
+	}
+	switch e.Final {
+	case 'c':
+		s.reset()
+	case '7': // DECSC
+		s.saveCursor()
+	case '8': // DECRC
+		s.restoreCursor()
+	case 'D':
+		s.execute(0x84)
+	case 'E':
+		s.execute(0x85)
+	case 'H':
+		s.execute(0x88)
+	case 'M':
+		s.execute(0x8d)
+	case 'N':
+		s.execute(0x8e)
+	case 'O':
+		s.execute(0x8f)
+	case 'n': // LS2
+		s.gl = 2
+	case 'o': // LS3
+		s.gl = 3
+	}
+}
+
+// escIntermediate handles the escape sequences with intermediates, which
+// designate the character sets.
+func (s *Screen) escIntermediate(e *ansi.ESC) {
+	g := -1
+	switch e.Intermediates[0] {
+	case '(':
+		g = 0
+	case ')':
+		g = 1
+	case '*':
+		g = 2
+	case '+':
+		g = 3
+	}
+	if g < 0 || len(e.Intermediates) > 1 {
+		return
+	}
+	if cs, ok := designate(e.Final); ok {
+		s.charsets[g] = cs
+	}
+}
+
+func (s *Screen) csi(c *ansi.CSI) {
+	if len(c.Intermediates) > 0 {
+		return
+	}
+
+	switch c.Private {
+	case 0:
+	case '?':
+		switch c.Final {
+		case 'h', 'l':
+			for i := range c.Params {
+				s.setMode(c.Param(i, 0), c.Final == 'h')
+			}
+		}
+		return
+	case '>':
+		// recordings made by earlier versions of ttyrec hide the cursor
+		// with CSI > 5 h and show it with CSI > 5 l
+		if c.Param(0, 0) == 5 && (c.Final == 'h' || c.Final == 'l') {
+			s.cursorVisible = c.Final == 'l'
+		}
+		return
+	default:
+		return
+	}
+
+	switch c.Final {
+	case 'A':
+		s.cursorUp(c.Param(0, 1))
+	case 'B':
+		s.cursorDown(c.Param(0, 1))
+	case 'C':
+		s.moveTo(s.x+c.Param(0, 1), s.y)
+	case 'D':
+		s.moveTo(s.x-c.Param(0, 1), s.y)
+	case 'E': // CNL
+		s.moveTo(0, s.y+c.Param(0, 1))
+	case 'F': // CPL
+		s.moveTo(0, s.y-c.Param(0, 1))
+	case 'G', '`': // CHA, HPA
+		s.moveTo(c.Param(0, 1)-1, s.y)
+	case 'd': // VPA
+		s.cursorTo(s.x, c.Param(0, 1)-1)
+	case 'H', 'f':
+		s.cursorTo(c.Param(1, 1)-1, c.Param(0, 1)-1)
+	case 'I': // CHT
+		for i := c.Param(0, 1); i > 0; i-- {
+			s.tab()
+		}
+	case 'Z': // CBT
+		for i := c.Param(0, 1); i > 0; i-- {
+			s.backTab()
+		}
+	case 'g': // TBC
+		switch c.Param(0, 0) {
+		case 0:
+			s.tabs[s.x] = false
+		case 3:
+			for x := range s.tabs {
+				s.tabs[x] = false
+			}
+		}
+	case 'J':
+		s.eraseDisplay(c.Param(0, 0))
+	case 'K':
+		s.eraseLine(c.Param(0, 0))
+	case '@':
+		s.insertChars(c.Param(0, 1))
+	case 'P':
+		s.deleteChars(c.Param(0, 1))
+	case 'X':
+		s.clearLine(s.y, s.x, s.x+c.Param(0, 1))
+	case 'b':
+		if s.last != 0 {
+			for i := c.Param(0, 1); i > 0; i-- {
+				s.print(s.last)
+			}
+		}
+	case 'L':
+		if s.inRegion() {
+			s.insertLinesAt(s.y, c.Param(0, 1))
+			s.moveTo(0, s.y)
+		}
+	case 'M':
+		if s.inRegion() {
+			s.deleteLinesAt(s.y, c.Param(0, 1))
+			s.moveTo(0, s.y)
+		}
+	case 'S':
+		s.scrollUp(c.Param(0, 1))
+	case 'T':
+		// with more parameters, this is xterm's mouse highlight tracking
+		if len(c.Params) <= 1 {
+			s.scrollDown(c.Param(0, 1))
+		}
+	case 'm':
+		s.sgr(c.Params)
+	case 'r':
+		top, bottom := c.Param(0, 1)-1, c.Param(1, s.height)-1
+		if top < bottom && bottom < s.height {
+			s.top, s.bottom = top, bottom
+			s.cursorTo(0, 0)
+		}
+	case 's':
+		// with parameters, this sets the left and right margins
+		if len(c.Params) == 0 {
+			s.saveCursor()
+		}
+	case 'u':
+		s.restoreCursor()
+	}
+}
+
+func (s *Screen) setMode(n int, on bool) {
+	switch n {
+	case 6: // DECOM
+		s.origin = on
+		s.cursorTo(0, 0)
+	case 7: // DECAWM
+		s.autowrap = on
+		s.wrapNext = false
+	case 25:
+		s.cursorVisible = on
+	case 47:
+		s.switchBuffer(on)
+	case 1047:
+		if !on && s.alt {
+			s.clearAll()
+		}
+		s.switchBuffer(on)
+	case 1048:
+		if on {
+			s.saveCursor()
+		} else {
+			s.restoreCursor()
+		}
+	case 1049:
+		if on {
+			s.saveCursor()
+			s.switchBuffer(true)
+			s.clearAll()
+		} else {
+			s.switchBuffer(false)
+			s.restoreCursor()
+		}
+	}
+}
+
+// cursorUp moves the cursor up n lines, stopping at the top margin when
+// it starts inside the scrolling region.
+func (s *Screen) cursorUp(n int) {
+	y := s.y - n
+	if s.inRegion() && y < s.top {
+		y = s.top
+	}
+	s.moveTo(s.x, y)
+}
+
+// cursorDown moves the cursor down n lines, stopping at the bottom margin
+// when it starts inside the scrolling region.
+func (s *Screen) cursorDown(n int) {
+	y := s.y + n
+	if s.inRegion() && y > s.bottom {
+		y = s.bottom
+	}
+	s.moveTo(s.x, y)
+}
+
+func (s *Screen) eraseDisplay(n int) {
+	switch n {
+	case 0:
+		s.clearLine(s.y, s.x, s.width)
+		for y := s.y + 1; y < s.height; y++ {
+			s.clearLine(y, 0, s.width)
+		}
+	case 1:
+		for y := 0; y < s.y; y++ {
+			s.clearLine(y, 0, s.width)
+		}
+		s.clearLine(s.y, 0, s.x+1)
+	case 2, 3:
+		for y := 0; y < s.height; y++ {
+			s.clearLine(y, 0, s.width)
+		}
+	}
+}
+
+func (s *Screen) eraseLine(n int) {
+	switch n {
+	case 0:
+		s.clearLine(s.y, s.x, s.width)
+	case 1:
+		s.clearLine(s.y, 0, s.x+1)
+	case 2:
+		s.clearLine(s.y, 0, s.width)
+	}
+}
+
+func (s *Screen) insertChars(n int) {
+	line := s.lines[s.y]
+	if n > s.width-s.x {
+		n = s.width - s.x
+	}
+	copy(line[s.x+n:], line[s.x:])
+	s.clearLine(s.y, s.x, s.x+n)
+	if last := &line[s.width-1]; last.Wide {
+		*last = s.blank()
+	}
+}
+
+func (s *Screen) deleteChars(n int) {
+	line := s.lines[s.y]
+	if n > s.width-s.x {
+		n = s.width - s.x
+	}
+	// blank the wide characters cut in half by the deletion
+	s.clearLine(s.y, s.x, s.x+n)
+	copy(line[s.x:], line[s.x+n:])
+	for x := s.width - n; x < s.width; x++ {
+		line[x] = s.blank()
+	}
+}
+
+func (s *Screen) sgr(ps [][]int) {
+	if len(ps) == 0 {
+		ps = [][]int{{0}}
+	}
+	for i := 0; i < len(ps); i++ {
+		n := ps[i][0]
+		switch {
+		case n == 0:
+			s.pen.FG, s.pen.BG, s.pen.Attr = DefaultColor, DefaultColor, 0
+		case n == 1:
+			s.pen.Attr |= Bold
+		case n == 2:
+			s.pen.Attr |= Faint
+		case n == 3:
+			s.pen.Attr |= Italic
+		case n == 4:
+			s.pen.Attr |= Underline
+		case n == 5 || n == 6:
+			s.pen.Attr |= Blink
+		case n == 7:
+			s.pen.Attr |= Reverse
+		case n == 8:
+			s.pen.Attr |= Invisible
+		case n == 9:
+			s.pen.Attr |= Strikethrough
+		case n == 21:
+			s.pen.Attr |= Underline
+		case n == 22:
+			s.pen.Attr &^= Bold | Faint
+		case n == 23:
+			s.pen.Attr &^= Italic
+		case n == 24:
+			s.pen.Attr &^= Underline
+		case n == 25:
+			s.pen.Attr &^= Blink
+		case n == 27:
+			s.pen.Attr &^= Reverse
+		case n == 28:
+			s.pen.Attr &^= Invisible
+		case n == 29:
+			s.pen.Attr &^= Strikethrough
+		case 30 <= n && n <= 37:
+			s.pen.FG = Indexed(uint8(n - 30))
+		case n == 38:
+			s.pen.FG, i = extendedColor(ps, i, s.pen.FG)
+		case n == 39:
+			s.pen.FG = DefaultColor
+		case 40 <= n && n <= 47:
+			s.pen.BG = Indexed(uint8(n - 40))
+		case n == 48:
+			s.pen.BG, i = extendedColor(ps, i, s.pen.BG)
+		case n == 49:
+			s.pen.BG = DefaultColor
+		case 90 <= n && n <= 97:
+			s.pen.FG = Indexed(uint8(n - 90 + 8))
+		case 100 <= n && n <= 107:
+			s.pen.BG = Indexed(uint8(n - 100 + 8))
+		}
+	}
+}
+
+// extendedColor parses the color selected by SGR 38 or 48 at ps[i], either
+// as sub-parameters (38:5:n, 38:2:cs:r:g:b or 38:2:r:g:b) or as the
+// following parameters (38;5;n, 38;2;r;g;b). It returns the color, or old
+// when it is malformed, and the index of the last parameter used.
+func extendedColor(ps [][]int, i int, old Color) (Color, int) {
+	args := ps[i][1:]
+	if len(args) == 0 {
+		// semicolon form, which takes the following parameters
+		rest := ps[i+1:]
+		switch {
+		case len(rest) >= 2 && rest[0][0] == 5:
+			return Indexed(clamp8(rest[1][0])), i + 2
+		case len(rest) >= 4 && rest[0][0] == 2:
+			return RGB(clamp8(rest[1][0]), clamp8(rest[2][0]), clamp8(rest[3][0])), i + 4
+		}
+		return old, len(ps) - 1
+	}
+
+	switch {
+	case args[0] == 5 && len(args) >= 2:
+		return Indexed(clamp8(args[1])), i
+	case args[0] == 2 && len(args) >= 5:
+		// the colon form may carry a color space id
+		return RGB(clamp8(args[2]), clamp8(args[3]), clamp8(args[4])), i
+	case args[0] == 2 && len(args) == 4:
+		return RGB(clamp8(args[1]), clamp8(args[2]), clamp8(args[3])), i
+	}
+	return old, i
+}
+
+func clamp8(n int) uint8 {
+	if n > 255 {
+		return 255
+	}
+	return uint8(n)
+}
@@ -0,0 +1,134 @@
+// Package script implements the timing files written by util-linux
+// script(1) and read by scriptreplay(1).
+//
+// The classic format, written by "script -t", has one "delay size" line per
+// chunk of output. The advanced format, written by "script --log-timing",
+// prefixes each line with a type: O and I for output and input, S for
+// signals and H for header information such as the terminal size.
+package script
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Entry types.
+const (
+	Output = 'O'
+	Input  = 'I'
+	Signal = 'S'
+	Info   = 'H'
+)
+
+// TimeLayout is the layout of START_TIME in advanced timing files and of the
+// date in the typescript header.
+const TimeLayout = "2006-01-02 15:04:05-07:00"
+
+// Entry is a single line of a timing file.
+type Entry struct {
+	Type  byte          // Output for classic timing files
+	Delay time.Duration // time since the previous entry
+	Size  int           // number of bytes for Output and Input
+	Name  string        // signal or header name for Signal and Info
+	Value string        // the rest of the line for Signal and Info
+}
+
+// TimingReader reads entries from a timing file in either format.
+type TimingReader struct {
+	s    *bufio.Scanner
+	line int
+}
+
+// NewTimingReader returns a new TimingReader reading from r.
+func NewTimingReader(r io.Reader) *TimingReader {
+	return &TimingReader{s: bufio.NewScanner(r)}
+}
+
+// Read reads the next entry. It returns io.EOF at the end of the file.
+func (r *TimingReader) Read() (*Entry, error) {
+	for r.s.Scan() {
+		r.line++
+		fields := strings.Fields(r.s.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		e, err := parseEntry(fields)
+		if err != nil {
+			return nil, fmt.Errorf("script: line %d: %v", r.line, err)
+		}
+		return e, nil
+	}
+	if err := r.s.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+func parseEntry(fields []string) (*Entry, error) {
+	e := &Entry{Type: Output}
+	if c := fields[0][0]; 'A' <= c && c <= 'Z' {
+		if len(fields[0]) != 1 || len(fields) < 3 {
+			return nil, fmt.Errorf("malformed entry %q", strings.Join(fields, " "))
+		}
+		e.Type = c
+		fields = fields[1:]
+	} else if len(fields) != 2 {
+		return nil, fmt.Errorf("malformed entry %q", strings.Join(fields, " "))
+	}
+
+	sec, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return nil, err
+	}
+	e.Delay = time.Duration(sec * float64(time.Second))
+
+	switch e.Type {
+	case Output, Input:
+		if e.Size, err = strconv.Atoi(fields[1]); err != nil {
+			return nil, err
+		}
+	default:
+		e.Name = fields[1]
+		e.Value = strings.Join(fields[2:], " ")
+	}
+	return e, nil
+}
+
+// TimingWriter writes entries to a timing file.
+type TimingWriter struct {
+	w        *bufio.Writer
+	advanced bool
+}
+
+// NewTimingWriter returns a new TimingWriter writing to w. If advanced is
+// false, the classic format is written and only Output entries are kept.
+func NewTimingWriter(w io.Writer, advanced bool) *TimingWriter {
+	return &TimingWriter{w: bufio.NewWriter(w), advanced: advanced}
+}
+
+// Write writes e as a single line.
+func (w *TimingWriter) Write(e *Entry) error {
+	delay := strconv.FormatFloat(e.Delay.Seconds(), 'f', 6, 64)
+	var err error
+	switch {
+	case !w.advanced:
+		if e.Type != Output {
+			return nil
+		}
+		_, err = fmt.Fprintf(w.w, "%s %d\n", delay, e.Size)
+	case e.Type == Output || e.Type == Input:
+		_, err = fmt.Fprintf(w.w, "%c %s %d\n", e.Type, delay, e.Size)
+	default:
+		_, err = fmt.Fprintf(w.w, "%c %s %s %s\n", e.Type, delay, e.Name, e.Value)
+	}
+	return err
+}
+
+// Flush writes any buffered entries to the underlying writer.
+func (w *TimingWriter) Flush() error {
+	return w.w.Flush()
+}
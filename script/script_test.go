@@ -0,0 +1,130 @@
+package script
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func readAll(t *testing.T, in string) []*Entry {
+	r := NewTimingReader(strings.NewReader(in))
+	var entries []*Entry
+	for {
+		e, err := r.Read()
+		if err == io.EOF {
+			return entries
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, e)
+	}
+}
+
+func TestClassic(t *testing.T) {
+	got := readAll(t, "0.500000 12\n\n1.25 3\n")
+	want := []Entry{
+		{Type: Output, Delay: 500 * time.Millisecond, Size: 12},
+		{Type: Output, Delay: 1250 * time.Millisecond, Size: 3},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("%d entries, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if *got[i] != want[i] {
+			t.Errorf("entry %d: %+v, want %+v", i, *got[i], want[i])
+		}
+	}
+}
+
+func TestAdvanced(t *testing.T) {
+	in := `H 0.000000 START_TIME 2021-03-04 05:06:07+09:00
+H 0.000000 COLUMNS 120
+H 0.000000 COMMAND bash -l
+O 0.100000 5
+I 0.200000 1
+S 0.300000 SIGWINCH ROWS=30 COLS=100
+`
+	got := readAll(t, in)
+	want := []Entry{
+		{Type: Info, Name: "START_TIME", Value: "2021-03-04 05:06:07+09:00"},
+		{Type: Info, Name: "COLUMNS", Value: "120"},
+		{Type: Info, Name: "COMMAND", Value: "bash -l"},
+		{Type: Output, Delay: 100 * time.Millisecond, Size: 5},
+		{Type: Input, Delay: 200 * time.Millisecond, Size: 1},
+		{Type: Signal, Delay: 300 * time.Millisecond, Name: "SIGWINCH", Value: "ROWS=30 COLS=100"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("%d entries, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if *got[i] != want[i] {
+			t.Errorf("entry %d: %+v, want %+v", i, *got[i], want[i])
+		}
+	}
+	if _, err := time.Parse(TimeLayout, got[0].Value); err != nil {
+		t.Errorf("START_TIME: %v", err)
+	}
+}
+
+func TestMalformed(t *testing.T) {
+	for _, in := range []string{
+		"0.5\n",
+		"0.5 12 3\n",
+		"abc 12\n",
+		"0.5 x\n",
+		"O 0.5\n",
+		"OO 0.5 1\n",
+	} {
+		_, err := NewTimingReader(strings.NewReader(in)).Read()
+		if err == nil || err == io.EOF {
+			t.Errorf("%q: got %v, want an error", in, err)
+		}
+	}
+
+	r := NewTimingReader(strings.NewReader("0.5 1\n0.5 x\n"))
+	r.Read()
+	if _, err := r.Read(); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("got %v, want an error on line 2", err)
+	}
+}
+
+func TestWriter(t *testing.T) {
+	entries := []*Entry{
+		{Type: Info, Name: "COLUMNS", Value: "80"},
+		{Type: Output, Delay: 100 * time.Millisecond, Size: 5},
+		{Type: Input, Delay: 200 * time.Millisecond, Size: 1},
+		{Type: Signal, Delay: 300 * time.Millisecond, Name: "SIGWINCH", Value: "ROWS=30 COLS=100"},
+	}
+	tests := []struct {
+		advanced bool
+		want     string
+	}{
+		{false, "0.100000 5\n"},
+		{true, "H 0.000000 COLUMNS 80\nO 0.100000 5\nI 0.200000 1\nS 0.300000 SIGWINCH ROWS=30 COLS=100\n"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		w := NewTimingWriter(&buf, tt.advanced)
+		for _, e := range entries {
+			if err := w.Write(e); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != tt.want {
+			t.Errorf("advanced %v: got\n%s\nwant\n%s", tt.advanced, buf.String(), tt.want)
+		}
+		// what was written reads back the same
+		got := readAll(t, buf.String())
+		for i, e := range got {
+			if tt.advanced && *e != *entries[i] {
+				t.Errorf("advanced: entry %d read back as %+v, want %+v", i, *e, *entries[i])
+			}
+		}
+	}
+}
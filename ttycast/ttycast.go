@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	enc "github.com/mattn/go-encoding"
+	"github.com/mattn/ttyrec4windows/asciicast"
+	"github.com/mattn/ttyrec4windows/format"
+	"golang.org/x/text/transform"
+)
+
+var (
+	flag_W = flag.Int("W", 0, "terminal width (default from metadata or 80)")
+	flag_H = flag.Int("H", 0, "terminal height (default from metadata or 24)")
+	flag_T = flag.String("T", "", "title (default from metadata)")
+	flag_e = flag.String("e", "", "encoding of the ttyrec recording (default from metadata or utf-8)")
+)
+
+// isAsciicast reports whether b starts with an asciicast JSON header rather
+// than a binary ttyrec header.
+func isAsciicast(b []byte) bool {
+	b = bytes.TrimLeft(b, " \t\r\n")
+	if len(b) < 2 || b[0] != '{' {
+		return false
+	}
+	return bytes.HasPrefix(bytes.TrimLeft(b[1:], " \t\r\n"), []byte(`"`)) &&
+		bytes.Contains(b, []byte(`"version"`))
+}
+
+func toAsciicast(r io.Reader, w io.Writer, meta *format.Meta) error {
+	encoding := *flag_e
+	if encoding == "" && meta != nil {
+		encoding = meta.Encoding
+	}
+	if encoding == "" {
+		encoding = "utf-8"
+	}
+	e := enc.GetEncoding(encoding)
+	if e == nil {
+		return errors.New("unknown encoding name")
+	}
+
+	fr := format.NewReader(r)
+	first, err := fr.ReadFrame()
+	if err == io.EOF {
+		first = &format.Frame{Time: time.Now()}
+	} else if err != nil {
+		return err
+	}
+
+	h := &asciicast.Header{
+		Width:     80,
+		Height:    24,
+		Timestamp: first.Time.Unix(),
+	}
+	if meta != nil {
+		if meta.Width > 0 && meta.Height > 0 {
+			h.Width, h.Height = meta.Width, meta.Height
+		}
+		h.Title = meta.Title
+		if h.Title == "" {
+			h.Title = meta.Command
+		}
+	}
+	if *flag_W > 0 {
+		h.Width = *flag_W
+	}
+	if *flag_H > 0 {
+		h.Height = *flag_H
+	}
+	if *flag_T != "" {
+		h.Title = *flag_T
+	}
+
+	cw, err := asciicast.NewWriter(w, h)
+	if err != nil {
+		return err
+	}
+
+	// asciicast is UTF-8, so the output is decoded; a character split
+	// across frames is held back by the decoder until it is complete
+	var buf bytes.Buffer
+	dec := transform.NewWriter(&buf, e.NewDecoder())
+	event := func(t time.Duration) error {
+		if buf.Len() == 0 {
+			return nil
+		}
+		err := cw.WriteEvent(&asciicast.Event{
+			Time: t,
+			Type: asciicast.Output,
+			Data: buf.String(),
+		})
+		buf.Reset()
+		return err
+	}
+
+	f := first
+	for {
+		if _, err = dec.Write(f.Data); err != nil {
+			return err
+		}
+		if err = event(f.Elapsed); err != nil {
+			return err
+		}
+
+		next, err := fr.ReadFrame()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		f = next
+	}
+	if err = dec.Close(); err != nil {
+		return err
+	}
+	if err = event(f.Elapsed); err != nil {
+		return err
+	}
+	return cw.Flush()
+}
+
+func toTtyrec(r io.Reader, w io.Writer) (*format.Meta, error) {
+	cr, err := asciicast.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Unix(cr.Header.Timestamp, 0)
+	if cr.Header.Timestamp == 0 {
+		start = time.Now()
+	}
+
+	bw := bufio.NewWriter(w)
+	fw := format.NewWriter(bw)
+	for {
+		e, err := cr.ReadEvent()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if e.Type != asciicast.Output {
+			continue
+		}
+		err = fw.WriteFrame(&format.Frame{
+			Time: start.Add(e.Time),
+			Data: []byte(e.Data),
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	meta := &format.Meta{
+		Width:    cr.Header.Width,
+		Height:   cr.Header.Height,
+		Title:    cr.Header.Title,
+		Encoding: "utf-8",
+		Start:    start,
+	}
+	return meta, bw.Flush()
+}
+
+func main() {
+	flag.Parse()
+
+	var in, out *os.File
+	var meta *format.Meta
+	var err error
+
+	switch flag.NArg() {
+	case 0:
+		in, out = os.Stdin, os.Stdout
+	case 1, 2:
+		in, err = os.Open(flag.Arg(0))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		defer in.Close()
+		meta, _ = format.LoadMeta(flag.Arg(0))
+		out = os.Stdout
+		if flag.NArg() == 2 {
+			out, err = os.Create(flag.Arg(1))
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+		}
+	default:
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	r, err := format.Decompress(in)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	br := bufio.NewReader(r)
+	b, _ := br.Peek(256)
+	if isAsciicast(b) {
+		meta, err = toTtyrec(br, out)
+		if err == nil && flag.NArg() == 2 {
+			if err = out.Close(); err == nil {
+				err = format.SaveMeta(flag.Arg(1), meta)
+			}
+		}
+	} else {
+		err = toAsciicast(br, out, meta)
+		if err == nil && flag.NArg() == 2 {
+			err = out.Close()
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/mattn/ttyrec4windows/format"
+)
+
+var (
+	flag_o = flag.String("o", "", "output file")
+	flag_g = flag.Duration("g", time.Second, "gap between recordings")
+	flag_c = flag.Bool("c", false, "reset the terminal between recordings")
+)
+
+// RIS also leaves the alternate screen and drops the scroll region, colors
+// and modes a recording may have ended with.
+const resetTerminal = "\x1bc"
+
+type concat struct {
+	w     *format.Writer
+	gap   time.Duration // time between recordings
+	reset bool          // reset the terminal between recordings
+	start time.Time     // time of the first frame written
+	end   time.Duration // elapsed time of the last frame written
+	n     int           // number of frames written
+}
+
+func (c *concat) add(filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	in, err := format.Decompress(f)
+	if err != nil {
+		return err
+	}
+	return c.read(filename, in)
+}
+
+// read appends the recording read from in, which is named filename in
+// errors and warnings.
+func (c *concat) read(filename string, in io.Reader) error {
+	base := c.end
+	if c.n > 0 {
+		base += c.gap
+		if c.reset {
+			if err := c.write(base, []byte(resetTerminal)); err != nil {
+				return err
+			}
+		}
+	}
+
+	r := format.NewReader(in)
+	for {
+		fr, err := r.ReadFrame()
+		if err == io.EOF {
+			return nil
+		}
+		if err == io.ErrUnexpectedEOF {
+			// keep the frames before the truncated one, as ttytime
+			// and ttyshot do
+			fmt.Fprintf(os.Stderr, "warning: %s: %v\n", filename, err)
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v", filename, err)
+		}
+		if c.n == 0 {
+			c.start = fr.Time
+		}
+		if err = c.write(base+fr.Elapsed, fr.Data); err != nil {
+			return err
+		}
+	}
+}
+
+func (c *concat) write(d time.Duration, data []byte) error {
+	c.end = d
+	c.n++
+	return c.w.WriteFrame(&format.Frame{Time: c.start.Add(d), Data: data})
+}
+
+func main() {
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	out := os.Stdout
+	if *flag_o != "" {
+		f, err := os.Create(*flag_o)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		out = f
+	}
+	bw := bufio.NewWriter(out)
+
+	c := &concat{w: format.NewWriter(bw), gap: *flag_g, reset: *flag_c}
+	var err error
+	for _, filename := range flag.Args() {
+		if err = c.add(filename); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = bw.Flush()
+	}
+	if *flag_o != "" {
+		if err == nil {
+			err = out.Close()
+		} else {
+			// do not leave a partial recording behind
+			out.Close()
+			os.Remove(*flag_o)
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if *flag_o != "" {
+		if meta, err := format.LoadMeta(flag.Arg(0)); err == nil {
+			meta.Start = c.start
+			if err = format.SaveMeta(*flag_o, meta); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+		}
+	}
+}
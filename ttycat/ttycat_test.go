@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/mattn/ttyrec4windows/format"
+)
+
+type frame struct {
+	elapsed time.Duration
+	data    string
+}
+
+func recording(t *testing.T, start time.Time, frames ...frame) []byte {
+	var rec bytes.Buffer
+	w := format.NewWriter(&rec)
+	for _, f := range frames {
+		if err := w.WriteFrame(&format.Frame{Time: start.Add(f.elapsed), Data: []byte(f.data)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return rec.Bytes()
+}
+
+func frames(t *testing.T, rec []byte) []frame {
+	var got []frame
+	r := format.NewReader(bytes.NewReader(rec))
+	for {
+		f, err := r.ReadFrame()
+		if err == io.EOF {
+			return got
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, frame{f.Elapsed, string(f.Data)})
+	}
+}
+
+func TestConcat(t *testing.T) {
+	a := recording(t, time.Unix(1000, 0), frame{0, "a"}, frame{time.Second, "b"})
+	b := recording(t, time.Unix(5000, 0), frame{0, "c"}, frame{2500 * time.Millisecond, "d"})
+
+	tests := []struct {
+		name  string
+		gap   time.Duration
+		reset bool
+		recs  [][]byte
+		want  []frame
+	}{
+		{
+			name: "gap",
+			gap:  5 * time.Second,
+			recs: [][]byte{a, b},
+			want: []frame{{0, "a"}, {time.Second, "b"}, {6 * time.Second, "c"}, {8500 * time.Millisecond, "d"}},
+		},
+		{
+			name:  "reset",
+			gap:   time.Second,
+			reset: true,
+			recs:  [][]byte{a, b},
+			want:  []frame{{0, "a"}, {time.Second, "b"}, {2 * time.Second, resetTerminal}, {2 * time.Second, "c"}, {4500 * time.Millisecond, "d"}},
+		},
+		{
+			name:  "no reset before the first recording",
+			reset: true,
+			recs:  [][]byte{b},
+			want:  []frame{{0, "c"}, {2500 * time.Millisecond, "d"}},
+		},
+		{
+			name: "truncated",
+			gap:  time.Second,
+			recs: [][]byte{a[:len(a)-1], b},
+			want: []frame{{0, "a"}, {time.Second, "c"}, {3500 * time.Millisecond, "d"}},
+		},
+	}
+	for _, test := range tests {
+		var out bytes.Buffer
+		c := &concat{w: format.NewWriter(&out), gap: test.gap, reset: test.reset}
+		for i, rec := range test.recs {
+			if err := c.read("rec", bytes.NewReader(rec)); err != nil {
+				t.Fatalf("%s: recording %d: %v", test.name, i, err)
+			}
+		}
+		got := frames(t, out.Bytes())
+		if len(got) != len(test.want) {
+			t.Errorf("%s: got %d frames %v, want %v", test.name, len(got), got, test.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != test.want[i] {
+				t.Errorf("%s: frame %d is %v %q, want %v %q", test.name, i, got[i].elapsed, got[i].data, test.want[i].elapsed, test.want[i].data)
+			}
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	enc "github.com/mattn/go-encoding"
+	"github.com/mattn/ttyrec4windows/format"
+	"github.com/mattn/ttyrec4windows/screen"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/transform"
+)
+
+var (
+	flag_s = flag.Duration("s", 0, "start time")
+	flag_E = flag.Duration("E", 0, "end time (default end of recording)")
+	flag_W = flag.Int("W", 0, "terminal width (default from metadata or 80)")
+	flag_H = flag.Int("H", 0, "terminal height (default from metadata or 24)")
+	flag_e = flag.String("e", "", "encoding (default from metadata or utf-8)")
+	flag_o = flag.String("o", "", "output file")
+)
+
+// cut writes the frames between start and end. ttyrec only records what
+// changed on the screen, so the frames before start are played into scr
+// and the cut opens with a frame which repaints it.
+func cut(r *format.Reader, w *format.Writer, scr *screen.Screen, e encoding.Encoding, start, end time.Duration) error {
+	dec := transform.NewWriter(scr, e.NewDecoder())
+	var first time.Time
+	skipped, opened := false, false
+
+	// open starts the cut with a repaint of the screen at start
+	open := func() error {
+		opened = true
+		if !skipped {
+			return nil
+		}
+		data, err := encoding.ReplaceUnsupported(e.NewEncoder()).Bytes(scr.Repaint())
+		if err != nil {
+			return err
+		}
+		return w.WriteFrame(&format.Frame{Time: first.Add(start), Data: data})
+	}
+
+	for n := 0; ; n++ {
+		f, err := r.ReadFrame()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			first = f.Time
+		}
+		if f.Elapsed < start {
+			if _, err = dec.Write(f.Data); err != nil {
+				return err
+			}
+			skipped = true
+			continue
+		}
+		// the repaint comes first even when start and end fall between
+		// the same two frames and it is all the cut shows
+		if !opened {
+			if err = open(); err != nil {
+				return err
+			}
+		}
+		if end > 0 && f.Elapsed > end {
+			break
+		}
+		if err = w.WriteFrame(f); err != nil {
+			return err
+		}
+	}
+	if !opened {
+		return fmt.Errorf("no frames between %v and %v", start, end)
+	}
+	return nil
+}
+
+func main() {
+	flag.Parse()
+	if flag.NArg() != 1 || (*flag_E > 0 && *flag_E < *flag_s) {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	f, err := os.Open(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer f.Close()
+
+	in, err := format.Decompress(f)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	// the screen is the size and encoding it was recorded with, unless
+	// -W, -H or -e give them
+	width, height, name := 80, 24, "utf-8"
+	meta, _ := format.LoadMeta(flag.Arg(0))
+	if meta != nil {
+		if meta.Width > 0 && meta.Height > 0 {
+			width, height = meta.Width, meta.Height
+		}
+		if meta.Encoding != "" {
+			name = meta.Encoding
+		}
+	}
+	if *flag_W > 0 {
+		width = *flag_W
+	}
+	if *flag_H > 0 {
+		height = *flag_H
+	}
+	if *flag_e != "" {
+		name = *flag_e
+	}
+	e := enc.GetEncoding(name)
+	if e == nil {
+		fmt.Fprintln(os.Stderr, "Unknown encoding name")
+		os.Exit(1)
+	}
+
+	out := os.Stdout
+	if *flag_o != "" {
+		out, err = os.Create(*flag_o)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+
+	bw := bufio.NewWriter(out)
+	err = cut(format.NewReader(in), format.NewWriter(bw), screen.New(width, height), e, *flag_s, *flag_E)
+	if err == nil {
+		err = bw.Flush()
+	}
+	if *flag_o != "" {
+		if err == nil {
+			err = out.Close()
+		} else {
+			// do not leave a partial recording behind
+			out.Close()
+			os.Remove(*flag_o)
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if *flag_o != "" {
+		if meta != nil {
+			meta.Start = meta.Start.Add(*flag_s)
+			meta.Width, meta.Height = width, height
+			meta.Encoding = name
+			if err = format.SaveMeta(*flag_o, meta); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/mattn/ttyrec4windows/format"
+	"github.com/mattn/ttyrec4windows/screen"
+	"golang.org/x/text/encoding/unicode"
+)
+
+func TestCut(t *testing.T) {
+	start := time.Unix(1000, 0)
+	frames := []struct {
+		elapsed time.Duration
+		data    string
+	}{
+		{0, "\x1b[1mone\x1b[m\r\n"},
+		{time.Second, "two\r\n"},
+		{5 * time.Second, "three\r\n"},
+		{10 * time.Second, "four"},
+	}
+	var rec bytes.Buffer
+	w := format.NewWriter(&rec)
+	for _, f := range frames {
+		if err := w.WriteFrame(&format.Frame{Time: start.Add(f.elapsed), Data: []byte(f.data)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		start, end time.Duration
+		repaint    int // number of frames the repaint replaces, or 0
+		frames     []int
+	}{
+		{name: "whole", frames: []int{0, 1, 2, 3}},
+		{name: "from a frame", start: time.Second, repaint: 1, frames: []int{1, 2, 3}},
+		{name: "start in a gap", start: 2 * time.Second, repaint: 2, frames: []int{2, 3}},
+		{name: "end in a gap", end: 7 * time.Second, frames: []int{0, 1, 2}},
+		{name: "start and end in gaps", start: 500 * time.Millisecond, end: 7 * time.Second, repaint: 1, frames: []int{1, 2}},
+		{name: "start and end in the same gap", start: 2 * time.Second, end: 4 * time.Second, repaint: 2},
+	}
+	for _, test := range tests {
+		var out bytes.Buffer
+		err := cut(format.NewReader(bytes.NewReader(rec.Bytes())), format.NewWriter(&out), screen.New(20, 5), unicode.UTF8, test.start, test.end)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+
+		r := format.NewReader(&out)
+		if test.repaint > 0 {
+			f, err := r.ReadFrame()
+			if err != nil {
+				t.Errorf("%s: %v", test.name, err)
+				continue
+			}
+			if !f.Time.Equal(start.Add(test.start)) {
+				t.Errorf("%s: repaint at %v, want %v", test.name, f.Time, start.Add(test.start))
+			}
+			want := screen.New(20, 5)
+			for _, f := range frames[:test.repaint] {
+				want.Write([]byte(f.data))
+			}
+			got := screen.New(20, 5)
+			got.Write(f.Data)
+			if got.String() != want.String() {
+				t.Errorf("%s: repaint shows\n%s\nwant\n%s", test.name, got, want)
+			}
+			if got.Cell(0, 0) != want.Cell(0, 0) {
+				t.Errorf("%s: repaint draws %+v, want %+v", test.name, got.Cell(0, 0), want.Cell(0, 0))
+			}
+		}
+		for _, n := range test.frames {
+			f, err := r.ReadFrame()
+			if err != nil {
+				t.Errorf("%s: frame %d: %v", test.name, n, err)
+				break
+			}
+			if want := start.Add(frames[n].elapsed); !f.Time.Equal(want) || string(f.Data) != frames[n].data {
+				t.Errorf("%s: got %v %q, want frame %d at %v %q", test.name, f.Time, f.Data, n, want, frames[n].data)
+			}
+		}
+		if _, err := r.ReadFrame(); err != io.EOF {
+			t.Errorf("%s: got %v after the last frame, want EOF", test.name, err)
+		}
+	}
+}
+
+func TestCutAfterEnd(t *testing.T) {
+	var rec bytes.Buffer
+	if err := format.NewWriter(&rec).WriteFrame(&format.Frame{Time: time.Unix(1000, 0), Data: []byte("one")}); err != nil {
+		t.Fatal(err)
+	}
+	err := cut(format.NewReader(&rec), format.NewWriter(io.Discard), screen.New(20, 5), unicode.UTF8, time.Minute, 0)
+	if err == nil {
+		t.Error("cut after the end of the recording succeeded")
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/mattn/ttyrec4windows/format"
+)
+
+var (
+	flag_o = flag.String("o", "", "write repaired copy")
+	flag_t = flag.Bool("t", false, "keep truncated frame")
+	flag_q = flag.Bool("q", false, "quiet")
+)
+
+func check(filename string, out string) (bool, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return false, err
+	}
+	if out != "" {
+		// creating the repaired copy over the recording would truncate it
+		// before it is read
+		if oi, err := os.Stat(out); err == nil && os.SameFile(fi, oi) {
+			return false, fmt.Errorf("%s: the repaired copy must not overwrite the recording", filename)
+		}
+	}
+
+	in, err := format.Decompress(f)
+	if err != nil {
+		return false, err
+	}
+
+	var w io.Writer
+	var of *os.File
+	if out != "" {
+		// write next to out and rename it into place once the check
+		// succeeded, so a failed run leaves no partial copy behind
+		of, err = os.CreateTemp(filepath.Dir(out), filepath.Base(out)+".*")
+		if err != nil {
+			return false, err
+		}
+		of.Chmod(fi.Mode().Perm())
+		w = of
+	}
+
+	rep, err := format.Check(in, w, &format.CheckOptions{Truncate: *flag_t})
+	if of != nil {
+		// the repaired copy is only complete once it is closed
+		if cerr := of.Close(); err == nil {
+			err = cerr
+		}
+		if err == nil {
+			err = os.Rename(of.Name(), out)
+		}
+		if err != nil {
+			os.Remove(of.Name())
+		}
+	}
+	if err != nil {
+		return false, err
+	}
+	for _, p := range rep.Problems {
+		fmt.Printf("%s: %s\n", filename, p)
+	}
+	if !*flag_q {
+		status := "ok"
+		if !rep.OK() {
+			status = "damaged"
+		}
+		fmt.Printf("%s: %d frames, %d bytes, %s\n", filename, rep.Frames, rep.Size, status)
+	}
+	return rep.OK(), nil
+}
+
+func main() {
+	flag.Parse()
+	if flag.NArg() == 0 || (*flag_o != "" && flag.NArg() != 1) {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	code := 0
+	for _, filename := range flag.Args() {
+		ok, err := check(filename, *flag_o)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		if !ok {
+			code = 1
+		}
+	}
+	os.Exit(code)
+}
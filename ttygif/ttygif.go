@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"image/gif"
+	"io"
+	"os"
+	"time"
+
+	"github.com/mattn/ttyrec4windows/render"
+	"github.com/mattn/ttyrec4windows/replay"
+)
+
+var (
+	flag_W = flag.Int("W", 0, "terminal width (default from metadata or 80)")
+	flag_H = flag.Int("H", 0, "terminal height (default from metadata or 24)")
+	flag_e = flag.String("e", "", "encoding (default from metadata or utf-8)")
+	flag_s = flag.Float64("s", 1.0, "speed")
+	flag_i = flag.Duration("i", 2*time.Second, "maximum idle time between frames (0 for no limit)")
+	flag_c = flag.Bool("c", true, "draw the cursor")
+	flag_l = flag.Int("l", 0, "loop count (0 loops forever, -1 plays once)")
+)
+
+const (
+	// minDelay is the shortest frame delay, in 1/100 seconds, which
+	// browsers honor; they slow down faster frames. Updates closer together
+	// are merged into one frame.
+	minDelay = 2
+	// endDelay is how long the last frame is shown.
+	endDelay = 100
+	// maxDelay is the longest delay a GIF frame can have.
+	maxDelay = 1<<16 - 1
+)
+
+// encoder collects the frames of an animated GIF. Each frame only holds
+// the rectangle which changed since the previous one.
+type encoder struct {
+	g       gif.GIF
+	prev    *image.Paletted // the screen after the last frame added
+	shown   *image.Paletted // the screen waiting to be added
+	shownAt int
+}
+
+// add shows img from t on. The image is copied.
+func (e *encoder) add(img *image.Paletted, t time.Duration) {
+	at := int(t / (10 * time.Millisecond))
+	switch {
+	case e.shown == nil:
+		e.shownAt = at
+	case bytes.Equal(img.Pix, e.shown.Pix):
+		return
+	case at-e.shownAt >= minDelay:
+		e.flush(at - e.shownAt)
+		e.shownAt = at
+	}
+	e.shown = clone(img, img.Rect)
+}
+
+// flush adds the waiting screen as a frame shown for delay.
+func (e *encoder) flush(delay int) {
+	r := e.shown.Rect
+	if e.prev != nil {
+		r = changed(e.prev, e.shown)
+		if r.Empty() {
+			e.wait(delay)
+			return
+		}
+	}
+	e.frame(clone(e.shown, r))
+	e.prev = e.shown
+	e.wait(delay)
+}
+
+// frame adds img as a frame with no delay yet.
+func (e *encoder) frame(img *image.Paletted) {
+	e.g.Image = append(e.g.Image, img)
+	e.g.Delay = append(e.g.Delay, 0)
+	e.g.Disposal = append(e.g.Disposal, gif.DisposalNone)
+}
+
+// wait adds delay to the last frame. A delay which does not fit is
+// continued by frames which repaint one pixel of the last screen.
+func (e *encoder) wait(delay int) {
+	for {
+		last := &e.g.Delay[len(e.g.Delay)-1]
+		n := maxDelay - *last
+		if n > delay {
+			n = delay
+		}
+		*last += n
+		delay -= n
+		if delay == 0 {
+			return
+		}
+		o := e.prev.Rect.Min
+		e.frame(clone(e.prev, image.Rectangle{Min: o, Max: o.Add(image.Pt(1, 1))}))
+	}
+}
+
+func (e *encoder) encode(w io.Writer) error {
+	if e.shown != nil {
+		e.flush(endDelay)
+	}
+	if len(e.g.Image) == 0 {
+		return errors.New("no frames")
+	}
+	first := e.g.Image[0]
+	e.g.Config = image.Config{
+		ColorModel: first.Palette,
+		Width:      first.Rect.Dx(),
+		Height:     first.Rect.Dy(),
+	}
+	return gif.EncodeAll(w, &e.g)
+}
+
+// changed returns the bounds of the pixels which differ between a and b.
+func changed(a, b *image.Paletted) image.Rectangle {
+	var r image.Rectangle
+	for y := b.Rect.Min.Y; y < b.Rect.Max.Y; y++ {
+		for x := b.Rect.Min.X; x < b.Rect.Max.X; x++ {
+			i := b.PixOffset(x, y)
+			if a.Pix[i] != b.Pix[i] {
+				r = r.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	return r
+}
+
+// clone returns a copy of the part r of img.
+func clone(img *image.Paletted, r image.Rectangle) *image.Paletted {
+	c := image.NewPaletted(r, img.Palette)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		copy(c.Pix[c.PixOffset(r.Min.X, y):], img.Pix[img.PixOffset(r.Min.X, y):img.PixOffset(r.Max.X, y)])
+	}
+	return c
+}
+
+func convert(p *replay.Player, w io.Writer) error {
+	e := &encoder{g: gif.GIF{LoopCount: *flag_l}}
+	img := render.NewImage(p.Screen, &render.DefaultPalette)
+	for {
+		t, err := p.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		render.Draw(img, p.Screen, *flag_c)
+		e.add(img, t)
+	}
+	return e.encode(w)
+}
+
+func main() {
+	flag.Parse()
+
+	if flag.NArg() > 2 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	p, err := replay.Open(flag.Arg(0), &replay.Options{
+		Width:    *flag_W,
+		Height:   *flag_H,
+		Encoding: *flag_e,
+		Speed:    *flag_s,
+		MaxIdle:  *flag_i,
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer p.Close()
+
+	out, err := replay.Create(flag.Arg(1))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	err = convert(p, out)
+	if err == nil {
+		err = out.Close()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
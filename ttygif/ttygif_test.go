@@ -0,0 +1,38 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"testing"
+	"time"
+)
+
+func TestLongDelay(t *testing.T) {
+	palette := color.Palette{color.Black, color.White}
+	a := image.NewPaletted(image.Rect(0, 0, 4, 4), palette)
+	b := image.NewPaletted(image.Rect(0, 0, 4, 4), palette)
+	b.SetColorIndex(2, 2, 1)
+
+	e := &encoder{}
+	e.add(a, 0)
+	e.add(b, time.Hour)
+	e.add(a, time.Hour+time.Second)
+	e.flush(endDelay)
+
+	// an hour is 360000 hundredths of a second, split into 6 frames
+	want := []int{maxDelay, maxDelay, maxDelay, maxDelay, maxDelay, 360000 - 5*maxDelay, 100, endDelay}
+	if len(e.g.Delay) != len(want) {
+		t.Fatalf("got delays %v, want %v", e.g.Delay, want)
+	}
+	for i := range want {
+		if e.g.Delay[i] != want[i] {
+			t.Errorf("got delays %v, want %v", e.g.Delay, want)
+			break
+		}
+	}
+	for i, img := range e.g.Image[1:6] {
+		if r := image.Rect(0, 0, 1, 1); img.Rect != r || img.Pix[0] != 0 {
+			t.Errorf("frame %d repeating the first one draws %v %v", i+1, img.Rect, img.Pix)
+		}
+	}
+}
@@ -0,0 +1,346 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mattn/ttyrec4windows/render"
+	"github.com/mattn/ttyrec4windows/replay"
+	"github.com/mattn/ttyrec4windows/screen"
+)
+
+var (
+	flag_W = flag.Int("W", 0, "terminal width (default from metadata or 80)")
+	flag_H = flag.Int("H", 0, "terminal height (default from metadata or 24)")
+	flag_e = flag.String("e", "", "encoding (default from metadata or utf-8)")
+	flag_i = flag.Duration("i", 2*time.Second, "maximum idle time between frames (0 for no limit)")
+	flag_c = flag.Bool("c", true, "draw the cursor")
+	flag_T = flag.String("T", "", "title (default from metadata)")
+)
+
+// recording is the data embedded in the page. Every distinct line is kept
+// once in Lines and frames refer to them by index, -1 for a blank line.
+type recording struct {
+	Width    int      `json:"width"`
+	Height   int      `json:"height"`
+	Duration int64    `json:"duration"`
+	Lines    []string `json:"lines"`
+	Frames   []frame  `json:"frames"`
+}
+
+// frame is a screen shown from T milliseconds on.
+type frame struct {
+	T     int64 `json:"t"`
+	Lines []int `json:"l"`
+}
+
+// lineHTML returns a line as HTML, with the cell at cursor drawn reversed.
+// Cells in the default colors and attributes are left unstyled.
+func lineHTML(cells []screen.Cell, cursor int) string {
+	var b strings.Builder
+	var style string
+	open := false
+	for x, c := range cells {
+		if c.Rune == 0 && x > 0 && cells[x-1].Wide {
+			continue
+		}
+		if x == cursor {
+			c.Attr ^= screen.Reverse
+		}
+		if s := cellStyle(c); !open || s != style {
+			if open && style != "" {
+				b.WriteString("</span>")
+			}
+			if s != "" {
+				fmt.Fprintf(&b, `<span style="%s">`, s)
+			}
+			style, open = s, true
+		}
+		switch {
+		case c.Rune == 0 || c.Attr&screen.Invisible != 0:
+			b.WriteByte(' ')
+		default:
+			b.WriteString(html.EscapeString(c.String()))
+		}
+	}
+	if open && style != "" {
+		b.WriteString("</span>")
+	}
+	return strings.TrimRight(b.String(), " ")
+}
+
+func cellStyle(c screen.Cell) string {
+	bold := c.Attr&screen.Bold != 0
+	fg, fgOK := render.DefaultPalette.RGBA(c.FG, bold)
+	bg, bgOK := render.DefaultPalette.RGBA(c.BG, false)
+	if c.Attr&screen.Reverse != 0 {
+		if !fgOK {
+			fg = render.DefaultPalette.Foreground
+		}
+		if !bgOK {
+			bg = render.DefaultPalette.Background
+		}
+		fg, bg = bg, fg
+		fgOK, bgOK = true, true
+	}
+
+	var s []string
+	if fgOK {
+		s = append(s, "color:"+render.Hex(fg))
+	}
+	if bgOK {
+		s = append(s, "background:"+render.Hex(bg))
+	}
+	if bold {
+		s = append(s, "font-weight:bold")
+	}
+	if c.Attr&screen.Italic != 0 {
+		s = append(s, "font-style:italic")
+	}
+	if c.Attr&screen.Faint != 0 {
+		s = append(s, "opacity:0.5")
+	}
+	var deco []string
+	if c.Attr&screen.Underline != 0 {
+		deco = append(deco, "underline")
+	}
+	if c.Attr&screen.Strikethrough != 0 {
+		deco = append(deco, "line-through")
+	}
+	if len(deco) > 0 {
+		s = append(s, "text-decoration:"+strings.Join(deco, " "))
+	}
+	return strings.Join(s, ";")
+}
+
+func convert(p *replay.Player, w io.Writer, title string) error {
+	rec := &recording{}
+	var ls render.LineSet
+	rec.Width, rec.Height = p.Screen.Size()
+	for {
+		t, err := p.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		ms := int64(t / time.Millisecond)
+		lines := ls.Snapshot(p.Screen, *flag_c, lineHTML)
+		if n := len(rec.Frames); n > 0 {
+			if render.SameLines(rec.Frames[n-1].Lines, lines) {
+				continue
+			}
+			if rec.Frames[n-1].T == ms {
+				rec.Frames[n-1].Lines = lines
+				continue
+			}
+		}
+		rec.Frames = append(rec.Frames, frame{T: ms, Lines: lines})
+	}
+	if len(rec.Frames) == 0 {
+		return errors.New("no frames")
+	}
+	rec.Duration = rec.Frames[len(rec.Frames)-1].T
+	rec.Lines = ls.Lines
+
+	// json escapes <, > and &, so the data cannot end the script element
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, page,
+		html.EscapeString(title),
+		render.Hex(render.DefaultPalette.Foreground),
+		render.Hex(render.DefaultPalette.Background),
+		data,
+		player)
+	return bw.Flush()
+}
+
+const page = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>%s</title>
+<style>
+body { margin: 1em; font-family: sans-serif; }
+#player { display: inline-block; }
+#term { margin: 0; padding: 0.5em; font: 14px/1.2 Consolas, Menlo, "DejaVu Sans Mono", monospace; color: %s; background: %s; }
+#term div { height: 1.2em; white-space: pre; overflow: hidden; }
+#controls { display: flex; align-items: center; gap: 0.5em; margin-top: 0.5em; }
+#seek { flex: 1; }
+#time { font-variant-numeric: tabular-nums; }
+</style>
+</head>
+<body>
+<div id="player">
+<div id="term"></div>
+<div id="controls">
+<button id="play">Play</button>
+<input id="seek" type="range" min="0" value="0">
+<span id="time"></span>
+<select id="speed">
+<option value="0.5">0.5x</option>
+<option value="1" selected>1x</option>
+<option value="2">2x</option>
+<option value="4">4x</option>
+</select>
+</div>
+</div>
+<script type="application/json" id="data">%s</script>
+<script>
+%s
+</script>
+</body>
+</html>
+`
+
+const player = `(function () {
+  var data = JSON.parse(document.getElementById("data").textContent);
+  var term = document.getElementById("term");
+  var play = document.getElementById("play");
+  var seek = document.getElementById("seek");
+  var time = document.getElementById("time");
+  var speed = document.getElementById("speed");
+
+  var rows = [], shown = [];
+  term.style.width = data.width + "ch";
+  for (var y = 0; y < data.height; y++) {
+    rows.push(term.appendChild(document.createElement("div")));
+    shown.push(null);
+  }
+  seek.max = data.duration;
+
+  var frame = -1, pos = 0, last = 0, playing = false;
+
+  // find returns the last frame shown at or before t.
+  function find(t) {
+    var lo = 0, hi = data.frames.length - 1;
+    while (lo < hi) {
+      var mid = (lo + hi + 1) >> 1;
+      if (data.frames[mid].t <= t) lo = mid; else hi = mid - 1;
+    }
+    return lo;
+  }
+
+  function clock(ms) {
+    var s = Math.floor(ms / 1000);
+    var m = Math.floor(s / 60);
+    s %= 60;
+    return m + ":" + (s < 10 ? "0" : "") + s;
+  }
+
+  function draw() {
+    var i = find(pos);
+    if (i !== frame) {
+      var lines = data.frames[i].l;
+      for (var y = 0; y < rows.length; y++) {
+        if (shown[y] !== lines[y]) {
+          rows[y].innerHTML = lines[y] < 0 ? "" : data.lines[lines[y]];
+          shown[y] = lines[y];
+        }
+      }
+      frame = i;
+    }
+    seek.value = pos;
+    time.textContent = clock(pos) + " / " + clock(data.duration);
+  }
+
+  function tick(now) {
+    if (!playing) return;
+    pos += (now - last) * parseFloat(speed.value);
+    last = now;
+    if (pos >= data.duration) {
+      pos = data.duration;
+      pause();
+    }
+    draw();
+    if (playing) requestAnimationFrame(tick);
+  }
+
+  function start() {
+    if (pos >= data.duration) pos = 0;
+    playing = true;
+    play.textContent = "Pause";
+    last = performance.now();
+    requestAnimationFrame(tick);
+  }
+
+  function pause() {
+    playing = false;
+    play.textContent = "Play";
+  }
+
+  play.addEventListener("click", function () {
+    if (playing) pause(); else start();
+  });
+  seek.addEventListener("input", function () {
+    pos = parseFloat(seek.value);
+    draw();
+  });
+  document.addEventListener("keydown", function (e) {
+    if (e.key === " " && e.target.tagName !== "SELECT") {
+      e.preventDefault();
+      if (playing) pause(); else start();
+    }
+  });
+  draw();
+})();`
+
+func main() {
+	flag.Parse()
+
+	if flag.NArg() > 2 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	p, err := replay.Open(flag.Arg(0), &replay.Options{
+		Width:    *flag_W,
+		Height:   *flag_H,
+		Encoding: *flag_e,
+		MaxIdle:  *flag_i,
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer p.Close()
+
+	title := "ttyrec"
+	if flag.NArg() > 0 {
+		title = filepath.Base(flag.Arg(0))
+	}
+	if p.Meta != nil && p.Meta.Title != "" {
+		title = p.Meta.Title
+	}
+	if *flag_T != "" {
+		title = *flag_T
+	}
+
+	out, err := replay.Create(flag.Arg(1))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	err = convert(p, out, title)
+	if err == nil {
+		err = out.Close()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
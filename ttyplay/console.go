@@ -0,0 +1,265 @@
+package main
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+
+	"github.com/mattn/ttyrec4windows/screen"
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	foregroundBlue      = 0x1
+	foregroundGreen     = 0x2
+	foregroundRed       = 0x4
+	foregroundIntensity = 0x8
+	foregroundMask      = (foregroundRed | foregroundBlue | foregroundGreen | foregroundIntensity)
+	backgroundBlue      = 0x10
+	backgroundGreen     = 0x20
+	backgroundRed       = 0x40
+	backgroundIntensity = 0x80
+	backgroundMask      = (backgroundRed | backgroundBlue | backgroundGreen | backgroundIntensity)
+)
+
+// attributes marking the two halves of a wide character
+const (
+	commonLvbLeadingByte  = 0x100
+	commonLvbTrailingByte = 0x200
+)
+
+var kernel32 = syscall.NewLazyDLL("kernel32.dll")
+
+var (
+	procGetConsoleScreenBufferInfo = kernel32.NewProc("GetConsoleScreenBufferInfo")
+	procGetConsoleCursorInfo       = kernel32.NewProc("GetConsoleCursorInfo")
+	procSetConsoleCursorInfo       = kernel32.NewProc("SetConsoleCursorInfo")
+	procSetConsoleCursorPosition   = kernel32.NewProc("SetConsoleCursorPosition")
+	procSetConsoleTextAttribute    = kernel32.NewProc("SetConsoleTextAttribute")
+	procWriteConsoleOutput         = kernel32.NewProc("WriteConsoleOutputW")
+	procGetConsoleTitle            = kernel32.NewProc("GetConsoleTitleW")
+	procSetConsoleTitle            = kernel32.NewProc("SetConsoleTitleW")
+)
+
+type wchar uint16
+type short int16
+type dword uint32
+type word uint16
+
+type coord struct {
+	x short
+	y short
+}
+
+type smallRect struct {
+	left   short
+	top    short
+	right  short
+	bottom short
+}
+
+type consoleScreenBufferInfo struct {
+	size              coord
+	cursorPosition    coord
+	attributes        word
+	window            smallRect
+	maximumWindowSize coord
+}
+
+type consoleCursorInfo struct {
+	size    dword
+	visible int32
+}
+
+type charInfo struct {
+	unicodeChar wchar
+	attributes  word
+}
+
+// ansiColors maps the ANSI palette to console foreground attributes.
+var ansiColors = [8]word{
+	0,
+	foregroundRed,
+	foregroundGreen,
+	foregroundRed | foregroundGreen,
+	foregroundBlue,
+	foregroundRed | foregroundBlue,
+	foregroundGreen | foregroundBlue,
+	foregroundRed | foregroundGreen | foregroundBlue,
+}
+
+// console renders a screen.Screen to the Windows console, rewriting only
+// the lines which changed since the last call to render.
+type console struct {
+	out    syscall.Handle
+	attr   word
+	window smallRect
+	cursor consoleCursorInfo
+	title  []uint16
+	shown  string
+	lines  [][]screen.Cell
+}
+
+func newConsole(f *os.File) (*console, error) {
+	c := &console{out: syscall.Handle(f.Fd())}
+	var csbi consoleScreenBufferInfo
+	r1, _, err := procGetConsoleScreenBufferInfo.Call(uintptr(c.out), uintptr(unsafe.Pointer(&csbi)))
+	if r1 == 0 {
+		return nil, err
+	}
+	c.attr = csbi.attributes
+	c.window = csbi.window
+	procGetConsoleCursorInfo.Call(uintptr(c.out), uintptr(unsafe.Pointer(&c.cursor)))
+	title := make([]uint16, 1024)
+	n, _, _ := procGetConsoleTitle.Call(uintptr(unsafe.Pointer(&title[0])), uintptr(len(title)))
+	c.title = append(title[:n:n], 0)
+	return c, nil
+}
+
+// size returns the width and height of the console window.
+func (c *console) size() (int, int) {
+	return int(c.window.right-c.window.left) + 1, int(c.window.bottom-c.window.top) + 1
+}
+
+// restore resets the text attributes and cursor changed while rendering.
+func (c *console) restore() {
+	procSetConsoleTextAttribute.Call(uintptr(c.out), uintptr(c.attr))
+	procSetConsoleCursorInfo.Call(uintptr(c.out), uintptr(unsafe.Pointer(&c.cursor)))
+	if len(c.title) > 1 {
+		procSetConsoleTitle.Call(uintptr(unsafe.Pointer(&c.title[0])))
+	}
+}
+
+// consoleColor returns the foreground attribute for color n of the 16
+// basic colors.
+func consoleColor(n int) word {
+	a := ansiColors[n&7]
+	if n&8 != 0 {
+		a |= foregroundIntensity
+	}
+	return a
+}
+
+func (c *console) attribute(cell screen.Cell) word {
+	fg := c.attr & foregroundMask
+	bg := c.attr & backgroundMask
+	if n := cell.FG.Nearest16(); n >= 0 {
+		fg = consoleColor(n)
+	}
+	if n := cell.BG.Nearest16(); n >= 0 {
+		bg = consoleColor(n) << 4
+	}
+	if cell.Attr&screen.Bold != 0 {
+		fg |= foregroundIntensity
+	}
+	if cell.Attr&screen.Reverse != 0 {
+		fg, bg = bg>>4, fg<<4
+	}
+	return fg | bg
+}
+
+// consoleRune returns the character the console shows for cell. The
+// console cannot draw combining marks, so they are composed with the base
+// character when Unicode has a precomposed form and dropped otherwise.
+func consoleRune(cell screen.Cell) wchar {
+	r := cell.Rune
+	if cell.Comb != "" {
+		if p := []rune(norm.NFC.String(cell.String())); len(p) == 1 {
+			r = p[0]
+		}
+	}
+	if r > 0xffff {
+		r = '?'
+	}
+	return wchar(r)
+}
+
+func sameLine(a, b []screen.Cell) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *console) render(s *screen.Screen) error {
+	sw, sh := s.Size()
+	cw, ch := c.size()
+	if len(c.lines) != sh {
+		c.lines = make([][]screen.Cell, sh)
+	}
+
+	for y := 0; y < sh && y < ch; y++ {
+		line := s.Line(y)
+		if sameLine(line, c.lines[y]) {
+			continue
+		}
+		n := sw
+		if n > cw {
+			n = cw
+		}
+		buf := make([]charInfo, n)
+		for x := range buf {
+			buf[x].attributes = c.attribute(line[x])
+			switch {
+			case line[x].Wide:
+				// the console wants a wide character in both of its cells
+				buf[x].unicodeChar = consoleRune(line[x])
+				buf[x].attributes |= commonLvbLeadingByte
+			case line[x].Rune == 0 && x > 0:
+				buf[x].unicodeChar = buf[x-1].unicodeChar
+				buf[x].attributes |= commonLvbTrailingByte
+			default:
+				buf[x].unicodeChar = consoleRune(line[x])
+			}
+		}
+		size := coord{x: short(n), y: 1}
+		var origin coord
+		region := smallRect{
+			left:   c.window.left,
+			top:    c.window.top + short(y),
+			right:  c.window.left + short(n) - 1,
+			bottom: c.window.top + short(y),
+		}
+		r1, _, err := procWriteConsoleOutput.Call(uintptr(c.out), uintptr(unsafe.Pointer(&buf[0])), uintptr(*(*int32)(unsafe.Pointer(&size))), uintptr(*(*int32)(unsafe.Pointer(&origin))), uintptr(unsafe.Pointer(&region)))
+		if r1 == 0 {
+			return err
+		}
+		c.lines[y] = line
+	}
+
+	x, y, visible := s.Cursor()
+	if x >= cw {
+		x = cw - 1
+	}
+	if y >= ch {
+		y = ch - 1
+	}
+	xy := coord{
+		x: c.window.left + short(x),
+		y: c.window.top + short(y),
+	}
+	r1, _, err := procSetConsoleCursorPosition.Call(uintptr(c.out), uintptr(*(*int32)(unsafe.Pointer(&xy))))
+	if r1 == 0 {
+		return err
+	}
+
+	cci := c.cursor
+	if !visible {
+		cci.visible = 0
+	}
+	procSetConsoleCursorInfo.Call(uintptr(c.out), uintptr(unsafe.Pointer(&cci)))
+
+	if title := s.Title(); title != c.shown {
+		p, err := syscall.UTF16PtrFromString(title)
+		if err == nil {
+			procSetConsoleTitle.Call(uintptr(unsafe.Pointer(p)))
+		}
+		c.shown = title
+	}
+	return nil
+}
@@ -2,7 +2,7 @@ package main
 
 import (
 	"bytes"
-	"encoding/binary"
+	"compress/gzip"
 	"flag"
 	"fmt"
 	"io"
@@ -14,6 +14,8 @@ import (
 	"time"
 	"unicode/utf16"
 	"unsafe"
+
+	"github.com/mattn/ttyrec4windows/format"
 )
 
 const (
@@ -27,6 +29,9 @@ const (
 	backgroundIntensity = 0x80
 )
 
+// commonLvbTrailingByte marks the second cell of a wide character.
+const commonLvbTrailingByte = 0x200
+
 var kernel32 = syscall.NewLazyDLL("kernel32.dll")
 
 var (
@@ -37,8 +42,7 @@ var (
 	procGetConsoleMode             = kernel32.NewProc("GetConsoleMode")
 	procFillConsoleOutputCharacter = kernel32.NewProc("FillConsoleOutputCharacterW")
 	procFillConsoleOutputAttribute = kernel32.NewProc("FillConsoleOutputAttribute")
-	procReadConsoleOutputCharacter = kernel32.NewProc("ReadConsoleOutputCharacterW")
-	procReadConsoleOutputAttribute = kernel32.NewProc("ReadConsoleOutputAttribute")
+	procReadConsoleOutput          = kernel32.NewProc("ReadConsoleOutputW")
 	procGetConsoleCursorInfo       = kernel32.NewProc("GetConsoleCursorInfo")
 )
 
@@ -98,11 +102,39 @@ type mouseEventRecord struct {
 	eventFlags      dword
 }
 
+// cellInfo is a console cell as read by ReadConsoleOutputW.
+type cellInfo struct {
+	unicodeChar wchar
+	attributes  word
+}
+
 type charInfo struct {
 	buf []rune
 	att []uint16
 }
 
+// readLine converts a row of console cells into runes and their colors. A
+// wide character fills two cells but is written to the terminal once, so
+// its trailing cell is skipped.
+func readLine(cells []cellInfo) ([]rune, []uint16) {
+	cb := make([]rune, 0, len(cells))
+	ca := make([]uint16, 0, len(cells))
+	for i := 0; i < len(cells); i++ {
+		c := cells[i]
+		if c.attributes&commonLvbTrailingByte != 0 {
+			continue
+		}
+		r := rune(c.unicodeChar)
+		if utf16.IsSurrogate(r) && i+1 < len(cells) {
+			r = utf16.DecodeRune(r, rune(cells[i+1].unicodeChar))
+			i++
+		}
+		cb = append(cb, r)
+		ca = append(ca, uint16(c.attributes&0xff))
+	}
+	return cb, ca
+}
+
 func fgToAnsi(a uint16) uint16 {
 	switch a%16 {
 	case 0:
@@ -183,12 +215,8 @@ func getSize(sr smallRect) coord {
 	return coord{sr.right - sr.left, sr.bottom - sr.top}
 }
 
-func writeBytes(f io.Writer, b []byte) {
-	t := time.Now().UnixNano() / 1000
-	binary.Write(f, binary.LittleEndian, uint32(t/1000000))
-	binary.Write(f, binary.LittleEndian, uint32(t%1000000))
-	binary.Write(f, binary.LittleEndian, uint32(len(b)))
-	f.Write(b)
+func writeBytes(w *format.Writer, b []byte) {
+	w.WriteFrame(&format.Frame{Time: time.Now(), Data: b})
 }
 
 func record(quit chan bool, wg *sync.WaitGroup, file string) {
@@ -203,7 +231,17 @@ func record(quit chan bool, wg *sync.WaitGroup, file string) {
 	}
 	defer f.Close()
 
-	writeBytes(f, []byte("\x1b[2J"))
+	var out io.Writer = f
+	var zw *gzip.Writer
+	if *flag_z {
+		zw = gzip.NewWriter(f)
+		defer zw.Close()
+		out = zw
+	}
+
+	w := format.NewWriter(out)
+	start := time.Now()
+	writeBytes(w, []byte("\x1b[2J"))
 	//fmt.Fprintf(f, "\x1b[c\x1b%%G\x1b[f\x1b[?7l")
 
 	var csbi consoleScreenBufferInfo
@@ -216,6 +254,17 @@ func record(quit chan bool, wg *sync.WaitGroup, file string) {
 	size := getSize(csbi.window)
 	tm := time.NewTicker(10 * time.Millisecond)
 
+	err = format.SaveMeta(file, &format.Meta{
+		Width:    int(size.x) + 1,
+		Height:   int(size.y) + 1,
+		Command:  *flag_e,
+		Encoding: "utf-8",
+		Start:    start,
+	})
+	if err != nil {
+		fmt.Println(err)
+	}
+
 	//fmt.Fprintf(f, "\x1b[8;%d;%dt\x1b[1;%dr", size.y, size.x, size.y)
 
 	var oldsize coord
@@ -253,34 +302,30 @@ loop:
 
 		l := uint32(size.x + 1)
 		buf := make([]charInfo, size.y+1)
-		var nr dword
-
 		var bb bytes.Buffer
 		for y := short(0); y < size.y+1; y++ {
-			xy := coord{
-				x: csbi.window.left,
-				y: csbi.window.top + y,
+			cells := make([]cellInfo, l)
+			bufSize := coord{x: short(l), y: 1}
+			var origin coord
+			region := smallRect{
+				left:   csbi.window.left,
+				top:    csbi.window.top + y,
+				right:  csbi.window.left + short(l) - 1,
+				bottom: csbi.window.top + y,
 			}
-			cbbuf := make([]uint16, l)
-			r1, _, err = procReadConsoleOutputCharacter.Call(uintptr(r), uintptr(unsafe.Pointer(&cbbuf[0])), uintptr(l), uintptr(*(*int32)(unsafe.Pointer(&xy))), uintptr(unsafe.Pointer(&nr)))
+			r1, _, err = procReadConsoleOutput.Call(uintptr(r), uintptr(unsafe.Pointer(&cells[0])), uintptr(*(*int32)(unsafe.Pointer(&bufSize))), uintptr(*(*int32)(unsafe.Pointer(&origin))), uintptr(unsafe.Pointer(&region)))
 			if r1 == 0 {
 				break loop
 			}
-			cb := utf16.Decode(cbbuf[:nr])
+			cb, ca := readLine(cells)
 			buf[y].buf = cb
-
-			ca := make([]uint16, l)
-			r1, _, err = procReadConsoleOutputAttribute.Call(uintptr(r), uintptr(unsafe.Pointer(&ca[0])), uintptr(l), uintptr(*(*int32)(unsafe.Pointer(&xy))), uintptr(unsafe.Pointer(&nr)))
-			if r1 == 0 {
-				break loop
-			}
-			buf[y].att = ca[:nr]
+			buf[y].att = ca
 
 			if len(oldbuf) > 0 {
 				ob := oldbuf[y].buf
 				oa := oldbuf[y].att
-				diff := false
-				for i := 0; i < len(cb); i++ {
+				diff := len(cb) != len(ob)
+				for i := 0; !diff && i < len(cb); i++ {
 					if cb[i] != ob[i] || ca[i] != oa[i] {
 						diff = true
 						break
@@ -306,14 +351,19 @@ loop:
 		}
 		if oldcurvis != curvis {
 			if curvis {
-				fmt.Fprintf(&bb, "\x1b[>5l")
+				fmt.Fprintf(&bb, "\x1b[?25h")
 			} else {
-				fmt.Fprintf(&bb, "\x1b[>5h")
+				fmt.Fprintf(&bb, "\x1b[?25l")
 			}
 		}
 
 		if bb.Len() > 0 {
-			writeBytes(f, bb.Bytes())
+			writeBytes(w, bb.Bytes())
+			if zw != nil {
+				// keep what was recorded readable if ttyrec is killed
+				// before the stream is closed
+				zw.Flush()
+			}
 			oldbuf = buf
 			oldcurpos = curpos
 			oldcurvis = curvis
@@ -384,7 +434,10 @@ func ttyTerm() {
 	setStdHandle(syscall.STD_OUTPUT_HANDLE, uintptr(syscall.Stdout))
 }
 
-var flag_e = flag.String("e", os.Getenv("COMSPEC"), "command")
+var (
+	flag_e = flag.String("e", os.Getenv("COMSPEC"), "command")
+	flag_z = flag.Bool("z", false, "gzip output")
+)
 
 func main() {
 	flag.Parse()
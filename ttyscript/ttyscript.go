@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/mattn/ttyrec4windows/format"
+	"github.com/mattn/ttyrec4windows/script"
+)
+
+var (
+	flag_x = flag.Bool("x", false, "export ttyrecord to timing and typescript")
+	flag_a = flag.Bool("a", false, "write advanced timing format")
+)
+
+const (
+	startedOn = "Script started on "
+	doneOn    = "Script done on "
+)
+
+func importScript(timing, typescript io.Reader, w io.Writer) (*format.Meta, error) {
+	tr := script.NewTimingReader(timing)
+	ts := bufio.NewReader(typescript)
+	t := time.Now()
+	if b, _ := ts.Peek(len(startedOn)); string(b) == startedOn {
+		line, err := ts.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		// util-linux follows the date with the terminal, and older
+		// versions write a date which does not parse and leaves the
+		// start at now
+		if date := line[len(startedOn):]; len(date) >= len(script.TimeLayout) {
+			if st, err := time.Parse(script.TimeLayout, date[:len(script.TimeLayout)]); err == nil {
+				t = st
+			}
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	fw := format.NewWriter(bw)
+	meta := &format.Meta{Start: t}
+	written := false
+	// Input shares the typescript when both logs name the same file.
+	var inputLog, outputLog string
+	for {
+		e, err := tr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		t = t.Add(e.Delay)
+
+		switch e.Type {
+		case script.Info:
+			switch e.Name {
+			case "START_TIME":
+				if st, err := time.Parse(script.TimeLayout, e.Value); err == nil && !written {
+					t = st
+					meta.Start = st
+				}
+			case "COLUMNS":
+				meta.Width, _ = strconv.Atoi(e.Value)
+			case "LINES":
+				meta.Height, _ = strconv.Atoi(e.Value)
+			case "COMMAND":
+				meta.Command = e.Value
+			case "INPUT_LOG":
+				inputLog = e.Value
+			case "OUTPUT_LOG":
+				outputLog = e.Value
+			}
+		case script.Input:
+			if inputLog != "" && inputLog == outputLog {
+				if _, err = io.CopyN(io.Discard, ts, int64(e.Size)); err != nil {
+					return nil, err
+				}
+			}
+		case script.Output:
+			data := make([]byte, e.Size)
+			n, err := io.ReadFull(ts, data)
+			if n > 0 {
+				if err := fw.WriteFrame(&format.Frame{Time: t, Data: data[:n]}); err != nil {
+					return nil, err
+				}
+				written = true
+			}
+			if err != nil {
+				bw.Flush()
+				return nil, fmt.Errorf("typescript is shorter than timing file: %v", err)
+			}
+		}
+	}
+	return meta, bw.Flush()
+}
+
+// writeInfo writes the header of an advanced timing file, with the size and
+// command taken from meta when the recording has one.
+func writeInfo(tw *script.TimingWriter, start time.Time, meta *format.Meta) error {
+	info := []*script.Entry{
+		{Type: script.Info, Name: "START_TIME", Value: start.Format(script.TimeLayout)},
+	}
+	if meta != nil {
+		if meta.Width > 0 && meta.Height > 0 {
+			info = append(info,
+				&script.Entry{Type: script.Info, Name: "COLUMNS", Value: strconv.Itoa(meta.Width)},
+				&script.Entry{Type: script.Info, Name: "LINES", Value: strconv.Itoa(meta.Height)})
+		}
+		if meta.Command != "" {
+			info = append(info, &script.Entry{Type: script.Info, Name: "COMMAND", Value: meta.Command})
+		}
+	}
+	for _, e := range info {
+		if err := tw.Write(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func exportScript(r io.Reader, meta *format.Meta, timing, typescript io.Writer) error {
+	fr := format.NewReader(r)
+	tw := script.NewTimingWriter(timing, *flag_a)
+	ts := bufio.NewWriter(typescript)
+
+	var start time.Time
+	var prev time.Duration
+	for n := 0; ; n++ {
+		f, err := fr.ReadFrame()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			start = f.Time
+			fmt.Fprintf(ts, "%s%s\n", startedOn, f.Time.Format(script.TimeLayout))
+			if *flag_a {
+				if err = writeInfo(tw, f.Time, meta); err != nil {
+					return err
+				}
+			}
+		}
+		if _, err = ts.Write(f.Data); err != nil {
+			return err
+		}
+		err = tw.Write(&script.Entry{
+			Type:  script.Output,
+			Delay: f.Elapsed - prev,
+			Size:  len(f.Data),
+		})
+		if err != nil {
+			return err
+		}
+		prev = f.Elapsed
+	}
+	if !start.IsZero() {
+		fmt.Fprintf(ts, "\n%s%s\n", doneOn, start.Add(prev).Format(script.TimeLayout))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	return ts.Flush()
+}
+
+func run() error {
+	if *flag_x {
+		if flag.NArg() != 3 {
+			flag.Usage()
+			os.Exit(1)
+		}
+		in, err := os.Open(flag.Arg(0))
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		r, err := format.Decompress(in)
+		if err != nil {
+			return err
+		}
+		timing, err := os.Create(flag.Arg(1))
+		if err != nil {
+			return err
+		}
+		defer timing.Close()
+		typescript, err := os.Create(flag.Arg(2))
+		if err != nil {
+			return err
+		}
+		defer typescript.Close()
+		meta, _ := format.LoadMeta(flag.Arg(0))
+		if err = exportScript(r, meta, timing, typescript); err != nil {
+			return err
+		}
+		// the files are only complete once they are closed
+		if err = timing.Close(); err != nil {
+			return err
+		}
+		return typescript.Close()
+	}
+
+	if flag.NArg() != 2 && flag.NArg() != 3 {
+		flag.Usage()
+		os.Exit(1)
+	}
+	timing, err := os.Open(flag.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer timing.Close()
+	typescript, err := os.Open(flag.Arg(1))
+	if err != nil {
+		return err
+	}
+	defer typescript.Close()
+	out := os.Stdout
+	if flag.NArg() == 3 {
+		out, err = os.Create(flag.Arg(2))
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+	}
+	meta, err := importScript(timing, typescript, out)
+	if err != nil {
+		return err
+	}
+	if flag.NArg() == 3 {
+		if err = out.Close(); err != nil {
+			return err
+		}
+		return format.SaveMeta(flag.Arg(2), meta)
+	}
+	return nil
+}
+
+func main() {
+	flag.Parse()
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mattn/ttyrec4windows/format"
+	"github.com/mattn/ttyrec4windows/render"
+	"github.com/mattn/ttyrec4windows/replay"
+)
+
+// clock is a time in a recording, given as a duration like 3m12s or as
+// [[hh:]mm:]ss[.fff].
+type clock time.Duration
+
+func (c *clock) String() string {
+	return time.Duration(*c).String()
+}
+
+func (c *clock) Set(s string) error {
+	if d, err := time.ParseDuration(s); err == nil {
+		*c = clock(d)
+		return nil
+	}
+	var d time.Duration
+	for _, part := range strings.Split(s, ":") {
+		v, err := strconv.ParseFloat(part, 64)
+		if err != nil || v < 0 {
+			return fmt.Errorf("bad time %q", s)
+		}
+		d = d*60 + time.Duration(v*float64(time.Second))
+	}
+	*c = clock(d)
+	return nil
+}
+
+var (
+	flag_W = flag.Int("W", 0, "terminal width (default from metadata or 80)")
+	flag_H = flag.Int("H", 0, "terminal height (default from metadata or 24)")
+	flag_e = flag.String("e", "", "encoding (default from metadata or utf-8)")
+	flag_c = flag.Bool("c", true, "draw the cursor")
+	flag_g = flag.Int("g", 0, "make a grid of this many screenshots across the recording")
+	flag_t clock
+	flag_p = render.DefaultPalette
+)
+
+func init() {
+	flag.Var(&flag_t, "t", "time of the screenshot, as 3m12s or 00:03:12 (default end of recording)")
+	flag.Var(&flag_p, "p", "colors, as fg=#rrggbb,bg=#rrggbb,0=#rrggbb,...,15=#rrggbb")
+}
+
+func flagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// ended reports whether err ends the recording, which is also the case
+// for a last frame cut short.
+func ended(err error) bool {
+	return err == io.EOF || err == io.ErrUnexpectedEOF
+}
+
+// screenshot returns the screen the player shows.
+func screenshot(p *replay.Player) *image.Paletted {
+	img := render.NewImage(p.Screen, &flag_p)
+	render.Draw(img, p.Screen, *flag_c)
+	return img
+}
+
+// grid returns n screenshots spread evenly from the start to the end of
+// the recording, each with its time below it.
+func grid(p *replay.Player, n int, length time.Duration) (*image.Paletted, error) {
+	cols := int(math.Ceil(math.Sqrt(float64(n))))
+	rows := (n + cols - 1) / cols
+	w, h := p.Screen.Size()
+	tw, th := w*render.CellWidth, (h+1)*render.CellHeight
+	gap := render.CellWidth
+	img := render.NewCanvas(image.Rect(0, 0, cols*(tw+gap)+gap, rows*(th+gap)+gap), &flag_p)
+
+	for i := 0; i < n; i++ {
+		var t time.Duration
+		if n > 1 {
+			t = length * time.Duration(i) / time.Duration(n-1)
+		}
+		if err := p.Seek(t); err != nil && !ended(err) {
+			return nil, err
+		}
+		tile := screenshot(p)
+		at := image.Pt(gap+i%cols*(tw+gap), gap+i/cols*(th+gap))
+		for y := 0; y < tile.Rect.Dy(); y++ {
+			copy(img.Pix[img.PixOffset(at.X, at.Y+y):], tile.Pix[tile.PixOffset(0, y):tile.PixOffset(tile.Rect.Dx(), y)])
+		}
+		render.DrawString(img, at.Add(image.Pt(0, th-render.CellHeight)), t.Round(time.Second).String())
+	}
+	return img, nil
+}
+
+func main() {
+	flag.Parse()
+
+	if flag.NArg() > 2 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	var length time.Duration
+	var err error
+	if *flag_g > 0 {
+		if flag.NArg() == 0 {
+			fmt.Fprintln(os.Stderr, "a grid needs a recording file")
+			os.Exit(1)
+		}
+		idx, err := format.IndexOf(flag.Arg(0))
+		if idx == nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: %s: %v\n", flag.Arg(0), err)
+		}
+		length = idx.Duration()
+	}
+
+	p, err := replay.Open(flag.Arg(0), &replay.Options{
+		Width:    *flag_W,
+		Height:   *flag_H,
+		Encoding: *flag_e,
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer p.Close()
+
+	out, err := replay.Create(flag.Arg(1))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	var img *image.Paletted
+	if *flag_g > 0 {
+		img, err = grid(p, *flag_g, length)
+	} else {
+		t := time.Duration(math.MaxInt64)
+		if flagSet("t") {
+			t = time.Duration(flag_t)
+		}
+		err = p.Seek(t)
+		if err == io.ErrUnexpectedEOF {
+			fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+		}
+		if ended(err) {
+			if p.Time() < t && flagSet("t") {
+				fmt.Fprintf(os.Stderr, "warning: the recording ends before %v\n", t)
+			}
+			err = nil
+		}
+		img = screenshot(p)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	err = png.Encode(out, img)
+	if err == nil {
+		err = out.Close()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"html"
+	"image/color"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mattn/ttyrec4windows/render"
+	"github.com/mattn/ttyrec4windows/replay"
+	"github.com/mattn/ttyrec4windows/screen"
+)
+
+var (
+	flag_W = flag.Int("W", 0, "terminal width (default from metadata or 80)")
+	flag_H = flag.Int("H", 0, "terminal height (default from metadata or 24)")
+	flag_e = flag.String("e", "", "encoding (default from metadata or utf-8)")
+	flag_s = flag.Float64("s", 1.0, "speed")
+	flag_i = flag.Duration("i", 2*time.Second, "maximum idle time between frames (0 for no limit)")
+	flag_c = flag.Bool("c", true, "draw the cursor")
+	flag_w = flag.Bool("w", false, "draw a window around the terminal")
+	flag_f = flag.String("f", "Consolas, Menlo, 'DejaVu Sans Mono', monospace", "font family")
+)
+
+const (
+	fontSize   = 14
+	cellWidth  = 0.6 * fontSize
+	cellHeight = 1.2 * fontSize
+	padding    = 10
+	titleBar   = 28
+	// endDelay is how long the last frame is shown before the animation
+	// starts over.
+	endDelay = time.Second
+)
+
+// frame is a screen, as the ids of its lines, and the time it is shown.
+type frame struct {
+	t     time.Duration
+	lines []int
+}
+
+func num(v float64) string {
+	return strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64)
+}
+
+// percent returns the position of t in total as a keyframe selector, with
+// enough digits to tell apart frames a millisecond apart.
+func percent(t, total time.Duration) string {
+	digits := 2
+	for ms := total / time.Millisecond; ms > 10000; ms /= 10 {
+		digits++
+	}
+	p := strconv.FormatFloat(100*float64(t)/float64(total), 'f', digits, 64)
+	p = strings.TrimRight(p, "0")
+	return strings.TrimSuffix(p, ".")
+}
+
+// colorOf returns the CSS color of c, or def for the default color.
+func colorOf(c screen.Color, bold bool, def color.RGBA) string {
+	if rgba, ok := render.DefaultPalette.RGBA(c, bold); ok {
+		return render.Hex(rgba)
+	}
+	return render.Hex(def)
+}
+
+// run is a sequence of cells of the same style.
+type run struct {
+	x, n int
+	text strings.Builder
+	cell screen.Cell
+}
+
+func sameStyle(a, b screen.Cell) bool {
+	return a.FG == b.FG && a.BG == b.BG && a.Attr == b.Attr
+}
+
+// lineDef returns the SVG elements of a line, with the cell at cursor
+// drawn reversed.
+func lineDef(cells []screen.Cell, cursor int) string {
+	var runs []*run
+	for x, c := range cells {
+		if x == cursor {
+			c.Attr ^= screen.Reverse
+		}
+		if c.Rune == 0 && x > 0 && cells[x-1].Wide {
+			runs[len(runs)-1].n++
+			continue
+		}
+		if len(runs) == 0 || !sameStyle(runs[len(runs)-1].cell, c) {
+			runs = append(runs, &run{x: x, cell: c})
+		}
+		r := runs[len(runs)-1]
+		r.n++
+		if c.Rune == 0 {
+			r.text.WriteByte(' ')
+		} else {
+			r.text.WriteString(c.String())
+		}
+	}
+
+	var b strings.Builder
+	for _, r := range runs {
+		c := r.cell
+		bold := c.Attr&screen.Bold != 0
+		fg := colorOf(c.FG, bold, render.DefaultPalette.Foreground)
+		bg := colorOf(c.BG, false, render.DefaultPalette.Background)
+		if c.Attr&screen.Reverse != 0 {
+			fg, bg = bg, fg
+		}
+		x, w := float64(r.x)*cellWidth, float64(r.n)*cellWidth
+		if bg != render.Hex(render.DefaultPalette.Background) {
+			fmt.Fprintf(&b, `<rect x="%s" width="%s" height="%s" fill="%s"/>`, num(x), num(w), num(cellHeight), bg)
+		}
+		text := strings.TrimRight(r.text.String(), " ")
+		if text == "" || c.Attr&screen.Invisible != 0 {
+			continue
+		}
+		// the font may not be as wide as the cells, so stretch the text
+		// over the cells it covers, without the trailing spaces
+		n := r.n - (r.text.Len() - len(text))
+		fmt.Fprintf(&b, `<text x="%s" y="%s" fill="%s"`, num(x), num(fontSize), fg)
+		if n > 1 {
+			fmt.Fprintf(&b, ` textLength="%s"`, num(float64(n)*cellWidth))
+		}
+		if bold {
+			b.WriteString(` font-weight="bold"`)
+		}
+		if c.Attr&screen.Italic != 0 {
+			b.WriteString(` font-style="italic"`)
+		}
+		if c.Attr&screen.Faint != 0 {
+			b.WriteString(` opacity="0.5"`)
+		}
+		var deco []string
+		if c.Attr&screen.Underline != 0 {
+			deco = append(deco, "underline")
+		}
+		if c.Attr&screen.Strikethrough != 0 {
+			deco = append(deco, "line-through")
+		}
+		if len(deco) > 0 {
+			fmt.Fprintf(&b, ` text-decoration="%s"`, strings.Join(deco, " "))
+		}
+		fmt.Fprintf(&b, `>%s</text>`, html.EscapeString(text))
+	}
+	return b.String()
+}
+
+func writeSVG(w io.Writer, width, height int, ls *render.LineSet, frames []frame) error {
+	tw, th := float64(width)*cellWidth, float64(height)*cellHeight
+	ox, oy := float64(padding), float64(padding)
+	if *flag_w {
+		oy += titleBar
+	}
+	sw, sh := tw+2*padding, th+oy+padding
+	bg := render.Hex(render.DefaultPalette.Background)
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, `<svg xmlns="http://www.w3.org/2000/svg" width="%s" height="%s" viewBox="0 0 %[1]s %[2]s" xml:space="preserve">`+"\n", num(sw), num(sh))
+
+	total := frames[len(frames)-1].t + endDelay
+	// frames which share a keyframe would hide each other, so only the
+	// last of them is kept
+	merged := frames[:1]
+	for _, f := range frames[1:] {
+		last := &merged[len(merged)-1]
+		if percent(f.t, total) == percent(last.t, total) {
+			last.lines = f.lines
+			continue
+		}
+		merged = append(merged, f)
+	}
+	frames = merged
+
+	fmt.Fprintf(bw, "<style>\ntext { font-family: %s; font-size: %dpx; white-space: pre; }\n", html.EscapeString(*flag_f), fontSize)
+	if len(frames) > 1 {
+		fmt.Fprintf(bw, ".strip { animation: play %sms steps(1, end) infinite; }\n@keyframes play {\n", num(float64(total/time.Millisecond)))
+		for i, f := range frames {
+			fmt.Fprintf(bw, "%s%% { transform: translateX(%spx); }\n", percent(f.t, total), num(float64(-i)*tw))
+		}
+		fmt.Fprintf(bw, "100%% { transform: translateX(%spx); }\n", num(float64(1-len(frames))*tw))
+		fmt.Fprintf(bw, "}\n")
+	}
+	fmt.Fprintf(bw, "</style>\n")
+
+	if *flag_w {
+		fmt.Fprintf(bw, `<rect width="100%%" height="100%%" rx="6" fill="%s"/>`+"\n", bg)
+		for i, c := range []string{"#ff5f58", "#ffbd2e", "#18c132"} {
+			fmt.Fprintf(bw, `<circle cx="%d" cy="%d" r="6" fill="%s"/>`+"\n", padding+6+i*20, padding+6, c)
+		}
+	} else {
+		fmt.Fprintf(bw, `<rect width="100%%" height="100%%" fill="%s"/>`+"\n", bg)
+	}
+
+	fmt.Fprintf(bw, "<defs>\n")
+	for id, def := range ls.Lines {
+		fmt.Fprintf(bw, `<g id="l%d">%s</g>`+"\n", id, def)
+	}
+	fmt.Fprintf(bw, "</defs>\n")
+
+	fmt.Fprintf(bw, `<svg x="%s" y="%s" width="%s" height="%s">`+"\n", num(ox), num(oy), num(tw), num(th))
+	fmt.Fprintf(bw, `<g class="strip">`+"\n")
+	for i, f := range frames {
+		fmt.Fprintf(bw, `<g transform="translate(%s)">`, num(float64(i)*tw))
+		for y, id := range f.lines {
+			if id >= 0 {
+				fmt.Fprintf(bw, `<use href="#l%d" y="%s"/>`, id, num(float64(y)*cellHeight))
+			}
+		}
+		fmt.Fprintf(bw, "</g>\n")
+	}
+	fmt.Fprintf(bw, "</g>\n</svg>\n</svg>\n")
+	return bw.Flush()
+}
+
+func convert(p *replay.Player, w io.Writer) error {
+	ls := &render.LineSet{}
+	var frames []frame
+	for {
+		t, err := p.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		lines := ls.Snapshot(p.Screen, *flag_c, lineDef)
+		if n := len(frames); n > 0 {
+			if render.SameLines(frames[n-1].lines, lines) {
+				continue
+			}
+			if frames[n-1].t == t {
+				frames[n-1].lines = lines
+				continue
+			}
+		}
+		frames = append(frames, frame{t: t, lines: lines})
+	}
+	if len(frames) == 0 {
+		return errors.New("no frames")
+	}
+	width, height := p.Screen.Size()
+	return writeSVG(w, width, height, ls, frames)
+}
+
+func main() {
+	flag.Parse()
+
+	if flag.NArg() > 2 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	p, err := replay.Open(flag.Arg(0), &replay.Options{
+		Width:    *flag_W,
+		Height:   *flag_H,
+		Encoding: *flag_e,
+		Speed:    *flag_s,
+		MaxIdle:  *flag_i,
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer p.Close()
+
+	out, err := replay.Create(flag.Arg(1))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	err = convert(p, out)
+	if err == nil {
+		err = out.Close()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
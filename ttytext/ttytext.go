@@ -0,0 +1,224 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mattn/ttyrec4windows/replay"
+)
+
+var (
+	flag_W = flag.Int("W", 0, "terminal width (default from metadata or 80)")
+	flag_H = flag.Int("H", 0, "terminal height (default from metadata or 24)")
+	flag_e = flag.String("e", "", "encoding (default from metadata or utf-8)")
+	flag_t = flag.Bool("t", false, "prefix lines with the time they appeared")
+)
+
+// row is a line of the screen and the time it got its text. done is set
+// once it was written while still on the screen.
+type row struct {
+	text string
+	t    time.Duration
+	done bool
+}
+
+// transcript turns the screens of a recording into lines of text. Lines
+// are written once they leave the screen, by scrolling or by the screen
+// being cleared, and the rest at the end. A line changed in place, such
+// as a prompt being typed on or a progress counter redrawn after a
+// carriage return, is written as it was last seen.
+type transcript struct {
+	w      io.Writer
+	window []row
+	y      int // the row of the window the cursor was on
+	stamps bool
+	err    error
+}
+
+func (tr *transcript) write(rows []row) {
+	for _, r := range rows {
+		if tr.err != nil {
+			return
+		}
+		if r.done {
+			continue
+		}
+		if tr.stamps {
+			d := r.t.Round(time.Millisecond)
+			_, tr.err = fmt.Fprintf(tr.w, "[%02d:%02d:%02d.%03d] ",
+				int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60, int(d/time.Millisecond)%1000)
+		}
+		if tr.err == nil {
+			_, tr.err = fmt.Fprintln(tr.w, r.text)
+		}
+	}
+}
+
+// trimBlank drops the blank rows at the end of rows.
+func trimBlank(rows []row) []row {
+	for len(rows) > 0 && rows[len(rows)-1].text == "" {
+		rows = rows[:len(rows)-1]
+	}
+	return rows
+}
+
+// related reports whether a row changed from a to b in place, like a line
+// being typed or erased back, rather than being replaced. A row which
+// becomes blank was cleared, not edited.
+func related(a, b string) bool {
+	if b == "" {
+		return a == ""
+	}
+	return a == "" || strings.HasPrefix(a, b) || strings.HasPrefix(b, a)
+}
+
+// scrolled returns by how many rows the screen moved up from the window to
+// cur, as the shift which lines up the most non-blank rows. It returns
+// len(cur) when the screen was cleared or replaced.
+func (tr *transcript) scrolled(cur []string) int {
+	h := len(cur)
+	best, score := 0, 0
+	for k := 0; k < h; k++ {
+		n := 0
+		for i := 0; i+k < h; i++ {
+			if cur[i] != "" && cur[i] == tr.window[i+k].text {
+				n++
+			}
+		}
+		if n > score {
+			best, score = k, n
+		}
+	}
+	if score > 0 {
+		return best
+	}
+	for i, r := range tr.window {
+		if i != tr.y && !related(r.text, cur[i]) {
+			return h
+		}
+	}
+	return 0
+}
+
+// update takes the screen shown from t on, with the cursor on row y.
+func (tr *transcript) update(cur []string, y int, t time.Duration) {
+	if len(tr.window) != len(cur) {
+		tr.write(trimBlank(tr.window))
+		tr.window = make([]row, len(cur))
+	}
+	k := tr.scrolled(cur)
+	if k == len(cur) {
+		tr.write(trimBlank(tr.window))
+	} else {
+		tr.write(tr.window[:k])
+	}
+
+	// a row replaced by another leaves the screen; the rows above it are
+	// written with it to keep their order. The row the cursor was on is
+	// redrawn rather than replaced, and only its last version is kept.
+	last := -1
+	for i, text := range cur {
+		if i+k < len(tr.window) && i+k != tr.y && !related(tr.window[i+k].text, text) {
+			last = i
+		}
+	}
+	tr.write(tr.window[k : k+last+1])
+
+	window := make([]row, len(cur))
+	for i, text := range cur {
+		var old row
+		if i+k < len(tr.window) {
+			old = tr.window[i+k]
+		}
+		if i <= last {
+			old.done = true
+		}
+		if !related(old.text, text) {
+			old = row{}
+		}
+		window[i] = row{text: text, t: old.t, done: old.done && old.text == text}
+		if old.text == "" {
+			window[i].t = t
+		}
+	}
+	tr.window = window
+	tr.y = y
+}
+
+func (tr *transcript) close() error {
+	tr.write(trimBlank(tr.window))
+	tr.window = nil
+	return tr.err
+}
+
+func convert(p *replay.Player, w io.Writer) error {
+	tr := &transcript{w: w, stamps: *flag_t}
+	_, h := p.Screen.Size()
+	var prev []string
+	for {
+		t, err := p.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		// full screen programs draw on the alternate screen, which is
+		// left out; the shell session around them is kept
+		if p.Screen.AltScreen() {
+			continue
+		}
+		cur := make([]string, h)
+		same := prev != nil
+		for y := range cur {
+			cur[y] = p.Screen.Text(y)
+			same = same && cur[y] == prev[y]
+		}
+		if same {
+			continue
+		}
+		_, y, _ := p.Screen.Cursor()
+		tr.update(cur, y, t)
+		prev = cur
+	}
+	return tr.close()
+}
+
+func main() {
+	flag.Parse()
+
+	if flag.NArg() > 2 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	p, err := replay.Open(flag.Arg(0), &replay.Options{
+		Width:    *flag_W,
+		Height:   *flag_H,
+		Encoding: *flag_e,
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer p.Close()
+
+	out, err := replay.Create(flag.Arg(1))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	err = convert(p, out)
+	if err == nil {
+		err = out.Close()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
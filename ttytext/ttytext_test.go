@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/mattn/ttyrec4windows/format"
+	"github.com/mattn/ttyrec4windows/replay"
+)
+
+func transcribe(t *testing.T, frames []string) string {
+	var rec bytes.Buffer
+	w := format.NewWriter(&rec)
+	for i, data := range frames {
+		f := &format.Frame{Time: time.Unix(int64(1000+i), 0), Data: []byte(data)}
+		if err := w.WriteFrame(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	p, err := replay.New(&rec, nil, &replay.Options{Width: 20, Height: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := convert(p, &out); err != nil {
+		t.Fatal(err)
+	}
+	return out.String()
+}
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		name   string
+		frames []string
+		want   string
+	}{
+		{
+			name:   "typing on a prompt",
+			frames: []string{"$ ", "l", "s", "\r\na b\r\n$ "},
+			want:   "$ ls\na b\n$\n",
+		},
+		{
+			name:   "erased back",
+			frames: []string{"$ lx", "\b \b", "s"},
+			want:   "$ ls\n",
+		},
+		{
+			name:   "scrolled",
+			frames: []string{"1\r\n2\r\n3\r\n4\r\n5", "\r\n6\r\n7"},
+			want:   "1\n2\n3\n4\n5\n6\n7\n",
+		},
+		{
+			name:   "cleared",
+			frames: []string{`C:\>`, "echo hi\r\nhi\r\n\r\n" + `C:\>`, "cls", "\x1b[H\x1b[2J" + `C:\>`, "dir\r\n"},
+			want:   "C:\\>echo hi\nhi\n\nC:\\>cls\nC:\\>dir\n",
+		},
+		{
+			name:   "line cleared",
+			frames: []string{"a\r\nprogress 1", "\r\x1b[K", "done"},
+			want:   "a\ndone\n",
+		},
+		{
+			name:   "line replaced",
+			frames: []string{"a\r\nfoo\r\n", "\x1b[Abar"},
+			want:   "a\nfoo\nbar\n",
+		},
+		{
+			name:   "line redrawn",
+			frames: []string{"a\r\nfoo", "\rbar"},
+			want:   "a\nbar\n",
+		},
+		{
+			name:   "counter",
+			frames: []string{"a\r\n", "\r1%", "\r50%", "\r100%", "\r\ndone"},
+			want:   "a\n100%\ndone\n",
+		},
+		{
+			name:   "counter scrolled",
+			frames: []string{"1\r\n2\r\n3\r\n4\r\n1%", "\r50%", "\r100%\r\n", "done"},
+			want:   "1\n2\n3\n4\n100%\ndone\n",
+		},
+		{
+			name:   "written rows scrolled off",
+			frames: []string{"a\r\nb\r\n", "\x1b[A\x1b[K", "c\r\n1\r\n2", "\r\n3\r\n4"},
+			want:   "a\nb\nc\n1\n2\n3\n4\n",
+		},
+	}
+	for _, tt := range tests {
+		if got := transcribe(t, tt.frames); got != tt.want {
+			t.Errorf("%s: got\n%q\nwant\n%q", tt.name, got, tt.want)
+		}
+	}
+}
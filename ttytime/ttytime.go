@@ -1,47 +1,90 @@
 package main
 
 import (
-	"encoding/binary"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"time"
+
+	"github.com/mattn/ttyrec4windows/format"
 )
 
-var flag_v = flag.Bool("v", false, "verbose")
+var (
+	flag_v = flag.Bool("v", false, "verbose")
+	flag_i = flag.Bool("i", false, "write index")
+)
 
 func calc_time(filename string) (int, error) {
+	if !*flag_v && !*flag_i {
+		idx, err := format.IndexOf(filename)
+		if idx == nil {
+			return 0, err
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", filename, err)
+		}
+		return int(idx.Duration() / time.Second), nil
+	}
+
 	f, err := os.Open(filename)
 	if err != nil {
 		return 0, err
 	}
 	defer f.Close()
 
-	var start, end [3]int32
-
-	err = binary.Read(f, binary.LittleEndian, &start)
+	in, err := format.Decompress(f)
 	if err != nil {
 		return 0, err
 	}
-	f.Seek(int64(start[2]), os.SEEK_CUR)
+
+	if *flag_i {
+		idx, err := format.BuildIndex(in, format.DefaultEvery)
+		if err != nil {
+			// a damaged recording is not indexed, and lasts as long as
+			// the frames which could be read
+			fmt.Fprintf(os.Stderr, "%s: %v\n", filename, err)
+			return int(idx.Duration() / time.Second), nil
+		}
+		if err = format.SaveIndex(filename, idx); err != nil {
+			return 0, err
+		}
+		return int(idx.Duration() / time.Second), nil
+	}
 
 	if *flag_v {
-		fmt.Printf("*** filename=%s, tv_sec=%d, tv_usec=%d, len=%d\n", filename, start[0], start[1], start[2])
+		if m, err := format.LoadMeta(filename); err == nil {
+			fmt.Printf("*** filename=%s, width=%d, height=%d, command=%s, encoding=%s\n", filename, m.Width, m.Height, m.Command, m.Encoding)
+		}
+	}
+
+	r := format.NewReader(in)
+
+	start, err := r.ReadFrame()
+	if err == io.EOF {
+		// an empty recording lasts no time, as its index says
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
 	}
+	end := start
 
 	for {
-		var h [3]int32
-		err = binary.Read(f, binary.LittleEndian, &h)
-		if err != nil {
-			break
+		if *flag_v {
+			fmt.Printf("*** filename=%s, tv_sec=%d, tv_usec=%d, len=%d\n", filename, end.Time.Unix(), end.Time.Nanosecond()/1000, len(end.Data))
 		}
-		f.Seek(int64(h[2]), os.SEEK_CUR)
-		end = h
 
-		if *flag_v {
-			fmt.Printf("*** filename=%s, tv_sec=%d, tv_usec=%d, len=%d\n", filename, h[0], h[1], h[2])
+		fr, err := r.ReadFrame()
+		if err != nil {
+			if err != io.EOF {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", filename, err)
+			}
+			break
 		}
+		end = fr
 	}
-	return int(end[0] - start[0]), nil
+	return int(end.Elapsed / time.Second), nil
 }
 
 func main() {
@@ -52,6 +95,6 @@ func main() {
 			fmt.Fprintln(os.Stderr, err)
 			os.Exit(1)
 		}
-		fmt.Printf("%7d	%s\n", n, filename);
+		fmt.Printf("%7d	%s\n", n, filename)
 	}
 }